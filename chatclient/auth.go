@@ -0,0 +1,97 @@
+package chatclient
+
+import (
+	"context"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+)
+
+// tokenCredentials sends a bearer token with every call, as servers with TokenAuth expect.
+type tokenCredentials struct {
+	token string
+}
+
+func (tc tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + tc.token}, nil
+}
+
+// RequireTransportSecurity keeps tokens off connections without TLS, where anyone who
+// can watch the connection could read them and log in as the client.
+func (tc tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// WithToken makes the client send token with every call, for servers that only let
+// known clients in. The name the client uses has to be the one the token belongs to.
+// The token is only sent over TLS, so it needs WithTLS as well, or Dial fails.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.dialOptions = append(c.dialOptions, grpc.WithPerRPCCredentials(tokenCredentials{token: token}))
+	}
+}
+
+// sessionCredentials sends the token of the session the client got from logging in.
+type sessionCredentials struct {
+	c *Client
+}
+
+func (sc sessionCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	sc.c.mutex.Lock()
+	token := sc.c.session
+	sc.c.mutex.Unlock()
+	if token == "" {
+		// not logged in yet, this is the call that does it
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity is true for the same reason as for tokenCredentials, and
+// because the password goes over the same connection when logging in.
+func (sc sessionCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// WithLogin makes the client log in to the account of its name with password before it
+// subscribes, and send the session token with every call, for servers with accounts.
+// If the server forgets the session, e.g. because it restarted, the client logs in again
+// when it reconnects. Do not use it together with WithToken. Like WithToken, it needs WithTLS.
+func WithLogin(password string) Option {
+	return func(c *Client) {
+		c.password = password
+		c.dialOptions = append(c.dialOptions, grpc.WithPerRPCCredentials(sessionCredentials{c: c}))
+	}
+}
+
+// WithRegistration is WithLogin, but registers the account first. Dial fails with an
+// AlreadyExists status if the name is taken.
+func WithRegistration(password string) Option {
+	return func(c *Client) {
+		WithLogin(password)(c)
+		c.register = true
+	}
+}
+
+// login logs in to the account of the client, registering it first if register is set,
+// and keeps the session token for later calls.
+func (c *Client) login(ctx context.Context, register bool) error {
+	credentials := &gRPC.Credentials{ClientName: c.name, Password: c.password}
+	var session *gRPC.Session
+	var err error
+	if register {
+		session, err = c.server.Register(ctx, credentials)
+	} else {
+		session, err = c.server.Login(ctx, credentials)
+	}
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.session = session.Token
+	c.mutex.Unlock()
+	c.logger.Printf("client %s: logged in, the session ends if it is not used until %s", c.name, time.Unix(0, session.ExpiresUnixNano).Format(time.DateTime))
+	return nil
+}
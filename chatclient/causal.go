@@ -0,0 +1,152 @@
+package chatclient
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hannaStokes/handin3/clock"
+)
+
+// holdBack is the hold-back queue used for causal delivery. A chat message is held until
+// every message it causally depends on and that this client gets has been delivered,
+// judged by its vector clock.
+//
+// delivered is the merge of the vectors of every delivered message. A chat message from
+// sender j with vector V can be delivered once V[k] <= delivered[k] for every entry k that
+// counts messages to one of the client's rooms or to the client itself, from anyone but j
+// (see clock.RoomEntry).
+// The sender's own entries are not checked, since the server already delivers the messages
+// of one sender in the order they were sent. Entries without a destination (the server's,
+// and each process's count of all its events) are not checked either, and messages from
+// the server (join, leave and system messages) are delivered at once: the server puts them
+// in order itself, and its vectors are what tell a client that just joined a room about
+// the history it depends on.
+type holdBack struct {
+	logger    *log.Logger
+	timeout   time.Duration
+	name      string          // the client's name, for the entries of direct messages to it
+	rooms     func() []string // the rooms the client is in right now
+	delivered clock.Vector
+	held      []heldMessage // in the order they were received
+}
+
+type heldMessage struct {
+	msg   Message
+	since time.Time
+}
+
+func newHoldBack(timeout time.Duration, name string, rooms func() []string, logger *log.Logger) *holdBack {
+	return &holdBack{logger: logger, timeout: timeout, name: name, rooms: rooms, delivered: clock.Vector{}}
+}
+
+// add takes a received message and returns the messages that can now be delivered, in order.
+func (h *holdBack) add(msg Message, now time.Time) []Message {
+	if msg.Clock.Kind != clock.KindVector || msg.Kind != KindChat {
+		// nothing to wait for
+		h.delivered.Merge(msg.Clock.Vector)
+		return append([]Message{msg}, h.release()...)
+	}
+	h.held = append(h.held, heldMessage{msg: msg, since: now})
+	out := h.release()
+	if len(h.held) > 0 && h.held[len(h.held)-1].msg.Sequence == msg.Sequence {
+		h.logger.Printf("Holding back #%d from %s until %s has been delivered", msg.Sequence, msg.ClientName, h.missing(msg))
+	}
+	return out
+}
+
+// expire delivers held messages that have waited longer than the timeout, even though some of
+// their dependencies are missing. They are returned with Forced set, followed by any message
+// that could be delivered because of them.
+func (h *holdBack) expire(now time.Time) []Message {
+	var out []Message
+	kept := h.held[:0]
+	for _, held := range h.held {
+		if now.Sub(held.since) < h.timeout {
+			kept = append(kept, held)
+			continue
+		}
+		h.logger.Printf("Gave up waiting for the causal dependencies %s of #%d from %s, delivering it anyway", h.missing(held.msg), held.msg.Sequence, held.msg.ClientName)
+		held.msg.Forced = true
+		h.delivered.Merge(held.msg.Clock.Vector)
+		out = append(out, held.msg)
+	}
+	h.held = kept
+	if len(out) == 0 {
+		return nil
+	}
+	return append(out, h.release()...)
+}
+
+// flush delivers everything still held, used when the subscription ends.
+func (h *holdBack) flush() []Message {
+	out := make([]Message, len(h.held))
+	for i, held := range h.held {
+		held.msg.Forced = true
+		out[i] = held.msg
+	}
+	h.held = nil
+	return out
+}
+
+// release delivers held messages until none of the remaining ones can be delivered.
+func (h *holdBack) release() []Message {
+	var out []Message
+	for {
+		i := h.next()
+		if i < 0 {
+			return out
+		}
+		msg := h.held[i].msg
+		h.held = append(h.held[:i], h.held[i+1:]...)
+		h.delivered.Merge(msg.Clock.Vector)
+		out = append(out, msg)
+	}
+}
+
+// next returns the index of the first held message that can be delivered, or -1.
+func (h *holdBack) next() int {
+	for i, held := range h.held {
+		if h.ready(held.msg) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *holdBack) ready(msg Message) bool {
+	return len(h.missing(msg)) == 0
+}
+
+// missing returns the entries msg is still waiting for.
+func (h *holdBack) missing(msg Message) clock.Vector {
+	rooms := make(map[string]bool)
+	for _, room := range h.rooms() {
+		rooms[room] = true
+	}
+	m := clock.Vector{}
+	for id, n := range msg.Clock.Vector {
+		if n > h.delivered[id] && h.waitsFor(msg, id, rooms) {
+			m[id] = n
+		}
+	}
+	return m
+}
+
+// waitsFor reports whether msg has to wait for the messages counted by the entry id.
+func (h *holdBack) waitsFor(msg Message, id string, rooms map[string]bool) bool {
+	// names cannot contain spaces, so the sender is everything before the first one
+	sender, _, ok := strings.Cut(id, " ")
+	if !ok || sender == msg.ClientName {
+		return false
+	}
+	if id == clock.DirectEntry(sender, h.name) {
+		return true
+	}
+	for room := range rooms {
+		if id == clock.RoomEntry(sender, room) {
+			return true
+		}
+	}
+	return false
+}
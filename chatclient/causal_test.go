@@ -0,0 +1,155 @@
+package chatclient
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/clock"
+)
+
+// newTestHoldBack makes the hold-back queue of the client called me, in rooms.
+func newTestHoldBack(timeout time.Duration, me string, rooms ...string) *holdBack {
+	return newHoldBack(timeout, me, func() []string { return rooms }, log.New(io.Discard, "", 0))
+}
+
+func chat(sequence int64, from, room string, v clock.Vector) Message {
+	return Message{ClientName: from, Kind: KindChat, Room: room, Sequence: sequence, Clock: clock.Time{Kind: clock.KindVector, Vector: v}}
+}
+
+func sequences(messages []Message) []int64 {
+	var s []int64
+	for _, m := range messages {
+		s = append(s, m.Sequence)
+	}
+	return s
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHoldBack(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := newTestHoldBack(time.Second, "carol", "general")
+
+	// bob answers alice, but the answer arrives first
+	answer := chat(2, "bob", "general", clock.Vector{"alice in general": 1, "bob in general": 1})
+	if out := h.add(answer, now); len(out) != 0 {
+		t.Fatalf("bob's answer was delivered before alice's message: %v", sequences(out))
+	}
+	// dave's message does not depend on anything
+	if out := h.add(chat(3, "dave", "general", clock.Vector{"dave in general": 1}), now); !equal(sequences(out), []int64{3}) {
+		t.Fatalf("delivered %v, want dave's message at once", sequences(out))
+	}
+	if out := h.add(chat(1, "alice", "general", clock.Vector{"alice in general": 1}), now); !equal(sequences(out), []int64{1, 2}) {
+		t.Fatalf("delivered %v, want alice's message and then bob's answer", sequences(out))
+	}
+	if len(h.held) != 0 {
+		t.Errorf("%d messages still held", len(h.held))
+	}
+}
+
+func TestHoldBackSkipsSenderAndServer(t *testing.T) {
+	h := newTestHoldBack(time.Second, "carol", "general", "games")
+	// the sender's own entries, the server's and everyone's count of all their events
+	// are not waited for
+	msg := chat(5, "alice", "general", clock.Vector{
+		"alice in general":     4,
+		"alice in games":       2,
+		"alice to carol":       1,
+		"bob":                  7,
+		clock.ServerID("main"): 9,
+	})
+	if out := h.add(msg, time.Unix(0, 0)); !equal(sequences(out), []int64{5}) {
+		t.Errorf("delivered %v, want the message at once", sequences(out))
+	}
+}
+
+// Entries of rooms the client is not in count messages it never gets, so they are not
+// waited for. Those of its other rooms and of direct messages to it are.
+func TestHoldBackOtherRooms(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := newTestHoldBack(time.Second, "carol", "general", "games")
+
+	// bob is in general and music, and saw dave's message in music
+	fromBob := chat(4, "bob", "general", clock.Vector{"bob in general": 2, "dave in music": 3, "dave to bob": 1})
+	if out := h.add(fromBob, now); !equal(sequences(out), []int64{4}) {
+		t.Fatalf("delivered %v, want bob's message at once", sequences(out))
+	}
+
+	// erin saw dave's message in games, and a direct message dave sent to carol
+	fromErin := chat(7, "erin", "general", clock.Vector{"dave in games": 1, "dave to carol": 1, "erin in general": 1})
+	if out := h.add(fromErin, now); len(out) != 0 {
+		t.Fatalf("delivered %v before dave's messages in games and to carol", sequences(out))
+	}
+	if out := h.add(chat(5, "dave", "games", clock.Vector{"dave in games": 1}), now); !equal(sequences(out), []int64{5}) {
+		t.Fatalf("delivered %v, want only dave's message in games", sequences(out))
+	}
+	direct := Message{ClientName: "dave", Kind: KindDirect, Sequence: 6, Clock: clock.Time{Kind: clock.KindVector, Vector: clock.Vector{"dave in games": 1, "dave to carol": 1}}}
+	if out := h.add(direct, now); !equal(sequences(out), []int64{6, 7}) {
+		t.Fatalf("delivered %v, want dave's direct message and then erin's message", sequences(out))
+	}
+	for _, msg := range []Message{fromBob, fromErin} {
+		if msg.Forced {
+			t.Errorf("#%d was forced", msg.Sequence)
+		}
+	}
+}
+
+func TestHoldBackServerMessages(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := newTestHoldBack(time.Second, "carol", "general")
+	h.add(chat(2, "bob", "general", clock.Vector{"alice in general": 1, "bob in general": 1}), now)
+	// a join carrying the history the client depends on releases bob's message
+	join := Message{ClientName: "dave", Kind: KindJoin, Room: "general", Sequence: 3, Clock: clock.Time{Kind: clock.KindVector, Vector: clock.Vector{"alice in general": 1}}}
+	if out := h.add(join, now); !equal(sequences(out), []int64{3, 2}) {
+		t.Errorf("delivered %v, want the join and then bob's message", sequences(out))
+	}
+	// messages without a vector clock are never held
+	plain := Message{ClientName: "erin", Kind: KindChat, Sequence: 4}
+	if out := h.add(plain, now); !equal(sequences(out), []int64{4}) {
+		t.Errorf("delivered %v, want the message at once", sequences(out))
+	}
+}
+
+func TestHoldBackExpire(t *testing.T) {
+	start := time.Unix(0, 0)
+	h := newTestHoldBack(time.Second, "dave", "general")
+	h.add(chat(2, "bob", "general", clock.Vector{"alice in general": 1, "bob in general": 1}), start)
+	h.add(chat(3, "carol", "general", clock.Vector{"bob in general": 1, "carol in general": 1}), start.Add(500*time.Millisecond))
+
+	if out := h.expire(start.Add(900 * time.Millisecond)); len(out) != 0 {
+		t.Fatalf("delivered %v before the timeout", sequences(out))
+	}
+	// bob's message gives up on alice, and carol's, which only waited for bob, follows it
+	out := h.expire(start.Add(time.Second))
+	if !equal(sequences(out), []int64{2, 3}) {
+		t.Fatalf("delivered %v, want bob's and then carol's message", sequences(out))
+	}
+	if !out[0].Forced || out[1].Forced {
+		t.Errorf("forced %v and %v, want only bob's message forced", out[0].Forced, out[1].Forced)
+	}
+}
+
+func TestHoldBackFlush(t *testing.T) {
+	h := newTestHoldBack(time.Minute, "dave", "general")
+	h.add(chat(2, "bob", "general", clock.Vector{"alice in general": 1, "bob in general": 1}), time.Unix(0, 0))
+	h.add(chat(4, "carol", "general", clock.Vector{"alice in general": 2, "carol in general": 1}), time.Unix(0, 0))
+	out := h.flush()
+	if !equal(sequences(out), []int64{2, 4}) || !out[0].Forced || !out[1].Forced {
+		t.Errorf("flush gave %+v, want both messages forced in the order received", out)
+	}
+	if len(h.held) != 0 {
+		t.Errorf("%d messages still held after flush", len(h.held))
+	}
+}
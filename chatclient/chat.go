@@ -0,0 +1,194 @@
+package chatclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startChat opens the Chat stream of l and says hello. It returns the Unimplemented status
+// if the server does not have Chat.
+func (c *Client) startChat(ctx context.Context, l *link, hello *gRPC.SubMessage) error {
+	stream, err := c.server.Chat(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&gRPC.Envelope{Body: &gRPC.Envelope_Hello{Hello: hello}}); err != nil && err != io.EOF {
+		return err
+	}
+	// the server answers with a hello of its own, or an error if it does not know Chat
+	// (a failed Send only returns io.EOF, the error itself is read here)
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.GetHello() == nil {
+		return fmt.Errorf("the server started the chat stream with %T instead of a hello", first.Body)
+	}
+	c.logger.Printf("client %s: chat stream open to server %s", c.name, first.GetHello().ClientName)
+	l.chat = stream
+	l.lastHeard.Store(time.Now().UnixNano())
+	l.recv = func() (*gRPC.ChatMessage, error) { return c.nextChat(l) }
+	return nil
+}
+
+// nextChat reads from the Chat stream until it gets a message for Messages, handling the
+// acks and pings that come before it. A leave from the server ends the stream like io.EOF.
+func (c *Client) nextChat(l *link) (*gRPC.ChatMessage, error) {
+	for {
+		env, err := l.chat.Recv()
+		if err != nil {
+			return nil, err
+		}
+		l.lastHeard.Store(time.Now().UnixNano())
+		switch body := env.Body.(type) {
+		case *gRPC.Envelope_Chat:
+			return body.Chat, nil
+
+		case *gRPC.Envelope_Ack:
+			c.answered(env)
+
+		case *gRPC.Envelope_Ping:
+			if body.Ping.Reply {
+				c.answered(env)
+				continue
+			}
+			if err := l.send(&gRPC.Envelope{Id: env.Id, Body: &gRPC.Envelope_Ping{Ping: &gRPC.Ping{Reply: true}}}); err != nil {
+				return nil, err
+			}
+
+		case *gRPC.Envelope_Leave:
+			c.logger.Printf("client %s: the server ended the chat stream: %s", c.name, body.Leave.Reason)
+			return nil, io.EOF
+
+		default:
+			c.logger.Printf("client %s: ignoring unexpected %T on the chat stream", c.name, env.Body)
+		}
+	}
+}
+
+// answered hands an ack or ping reply to whoever is waiting for it in request.
+func (c *Client) answered(env *gRPC.Envelope) {
+	c.mutex.Lock()
+	reply, ok := c.pending[env.Id]
+	c.mutex.Unlock()
+	if ok {
+		reply <- env
+	}
+}
+
+// send sends env on the Chat stream of l. Sends from different goroutines are taken in turn.
+func (l *link) send(env *gRPC.Envelope) error {
+	l.sendMutex.Lock()
+	defer l.sendMutex.Unlock()
+	return l.chat.Send(env)
+}
+
+// request sends env with a new id on the Chat stream of l and waits for the envelope that answers it.
+func (c *Client) request(ctx context.Context, l *link, env *gRPC.Envelope) (*gRPC.Envelope, error) {
+	reply := make(chan *gRPC.Envelope, 1)
+	c.mutex.Lock()
+	c.nextID++
+	env.Id = c.nextID
+	c.pending[env.Id] = reply
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, env.Id)
+		c.mutex.Unlock()
+	}()
+
+	if err := l.send(env); err != nil {
+		if err == io.EOF {
+			return nil, status.Error(codes.Unavailable, "the chat stream has ended")
+		}
+		return nil, err
+	}
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-l.done:
+		return nil, status.Error(codes.Unavailable, "the chat stream has ended")
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// publishChat sends message on the Chat stream and waits for the server to accept it.
+// A rejected message gives the same status error as Publish would have.
+func (c *Client) publishChat(ctx context.Context, l *link, message *gRPC.ChatMessage) (*gRPC.ChatAccept, error) {
+	res, err := c.request(ctx, l, &gRPC.Envelope{Body: &gRPC.Envelope_Chat{Chat: message}})
+	if err != nil {
+		return nil, err
+	}
+	ack := res.GetAck()
+	if ack == nil {
+		return nil, fmt.Errorf("the server answered a message with %T instead of an ack", res.Body)
+	}
+	if ack.Code != 0 {
+		return nil, status.FromProto(&spb.Status{Code: ack.Code, Message: ack.Error, Details: ack.Details}).Err()
+	}
+	return ack.Accept, nil
+}
+
+// heartbeats pings the server on the Chat stream of l once per heartbeat interval until ctx
+// is done, and reports the server as unreachable once it has missed too many heartbeats in
+// a row. A heartbeat counts as missed if nothing at all was heard from the server since the last one.
+func (c *Client) heartbeats(ctx context.Context, l *link) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, l.lastHeard.Load())) < c.heartbeat {
+			missed = 0
+		} else {
+			missed++
+			c.logger.Printf("client %s: missed heartbeat %d of %d from the server", c.name, missed, c.misses)
+		}
+		if missed >= c.misses {
+			err := status.Errorf(codes.Unavailable, "server unreachable, nothing heard for %d heartbeats", missed)
+			c.logger.Printf("client %s: %v", c.name, err)
+			c.mutex.Lock()
+			l.err = err
+			c.mutex.Unlock()
+			// ends the stream, and receive takes l.err as the reason instead of the cancellation
+			l.cancel()
+			return
+		}
+		// skipped if something is being sent already, so a stuck connection cannot block the checks
+		if l.sendMutex.TryLock() {
+			l.chat.Send(&gRPC.Envelope{Body: &gRPC.Envelope_Ping{Ping: &gRPC.Ping{}}})
+			l.sendMutex.Unlock()
+		}
+	}
+}
+
+// Ping asks the server to answer on the Chat stream and returns how long that took.
+// It only works with servers that have Chat.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	l, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+	if l.chat == nil {
+		return 0, errors.New("ping needs a server with the Chat stream")
+	}
+	start := time.Now()
+	if _, err := c.request(ctx, l, &gRPC.Envelope{Body: &gRPC.Envelope_Ping{Ping: &gRPC.Ping{}}}); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,764 @@
+// Package chatclient lets Go programs talk to a ChittyChat server without going
+// through the terminal client in client/client.go.
+package chatclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	// this has to be the same as the go.mod module,
+	// followed by the path to the folder the proto file is in.
+	"github.com/hannaStokes/handin3/clock"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+// Kind tells what a received Message is about.
+type Kind = gRPC.MessageKind
+
+const (
+	KindChat   = gRPC.MessageKind_CHAT
+	KindJoin   = gRPC.MessageKind_JOIN
+	KindLeave  = gRPC.MessageKind_LEAVE
+	KindSystem = gRPC.MessageKind_SYSTEM
+	KindDirect = gRPC.MessageKind_DIRECT // a private message sent with SendDirect
+)
+
+// DefaultRoom is the room a client starts in unless WithRoom says otherwise.
+const DefaultRoom = "general"
+
+// Message is a message received from the server.
+type Message struct {
+	ClientName string // who the message is from, or who joined/left
+	Text       string
+	Kind       Kind
+	Room       string // the room the message was sent in, empty if it was sent to everyone or directly
+	Sequence   int64  // position of the message in the order the server broadcast them
+	Timestamp  int64  // Lamport time the server sent with the message
+	LocalTime  int64  // Lamport time of this client after receiving the message
+	ID         string // the id its sender gave the message, empty if it gave none
+	Account    string // the account the server says sent the message, empty if it does not check
+
+	// Clock is the time of the sender's extra logical clock when it sent the message,
+	// the zero Time if it only used the Lamport timestamp.
+	Clock clock.Time
+	// ConcurrentWith holds the sequence numbers of recently received messages whose Clock
+	// is concurrent with this one, meaning neither could have caused the other.
+	// Only vector clocks can tell this, so it stays empty for other clocks.
+	ConcurrentWith []int64
+	// Forced is set when causal delivery gave up waiting for messages this one depends on.
+	Forced bool
+}
+
+// recentClocks is how many received clock times are kept to find concurrent messages.
+const recentClocks = 50
+
+// Client is a connection to a ChittyChat server. It is subscribed from Dial until Close.
+type Client struct {
+	name        string
+	logger      *log.Logger
+	dialOptions []grpc.DialOption
+	tlsConfig   *tls.Config // nil to connect without TLS
+	password    string      // to log in with, empty to not log in. See auth.go.
+	register    bool        // register the account before logging in the first time
+	bufferSize  int
+	inboxSize   int // how many received messages can wait for the Messages channel, see inbox.go
+	onJoin      func(name, room string)
+	onLeave     func(name, room string)
+	clockKind   clock.Kind
+	causal      time.Duration // how long causal delivery waits for missing messages, 0 if it is off
+	heartbeat   time.Duration // how often the server is pinged on the Chat stream, 0 to not ping it
+	misses      int           // heartbeats the server can miss before it is reported unreachable
+	resumeFrom  int64         // sequence number to resume after, 0 for a new subscription
+	backoff     Backoff       // how to reconnect, the zero Backoff to not reconnect
+	onState     func(State)
+
+	conn   *grpc.ClientConn
+	server gRPC.ChittyChatClient
+	cancel context.CancelFunc
+
+	pending map[int64]chan *gRPC.Envelope // waiting for an ack or ping reply, by envelope id. Guarded by mutex
+	nextID  int64                         // id of the last envelope that needed an answer. Guarded by mutex
+
+	lastSequence atomic.Int64 // sequence number of the last message taken from the inbox to be delivered
+	received     atomic.Int64 // sequence number of the last message read from the server, where a new link resumes
+
+	lamport  *clock.Lamport
+	logical  clock.Clock // the extra clock chosen with WithClock, nil if it is just Lamport
+	messages chan Message
+
+	// only used by the subscribe goroutine
+	holdBack *holdBack // nil unless causal delivery is on
+	recent   []Message // last delivered messages with a clock time
+
+	mutex   sync.Mutex
+	err     error    // why the subscription ended, set before messages is closed
+	room    string   // the room Send sends to
+	rooms   []string // the rooms the client is in, sorted
+	link    *link    // the current subscription, nil while reconnecting, see reconnect.go
+	state   State
+	session string // token of the session from logging in, see auth.go
+}
+
+// Room is a chat room on the server, as returned by ListRooms.
+type Room struct {
+	Name    string
+	Members []string // names of the clients in the room, sorted
+}
+
+// Option configures a Client created by Dial.
+type Option func(*Client)
+
+// WithName sets the client name used when subscribing and publishing. Defaults to "default".
+func WithName(name string) Option {
+	return func(c *Client) { c.name = name }
+}
+
+// WithRoom sets the room the client starts in. Defaults to DefaultRoom.
+func WithRoom(room string) Option {
+	return func(c *Client) { c.room = room }
+}
+
+// WithLogger makes the client log to l instead of the standard logger.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) { c.logger = l }
+}
+
+// WithDialOptions passes extra options on to grpc.DialContext.
+// Without any, or WithTLS, the client connects with insecure credentials.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) { c.dialOptions = append(c.dialOptions, opts...) }
+}
+
+// WithBufferSize sets how many received messages the Messages channel holds. Defaults to 100.
+// Messages received while it is full wait in memory, see WithInboxSize, as the client keeps
+// reading from the server so the answers to Send are not stuck behind them.
+func WithBufferSize(n int) Option {
+	return func(c *Client) { c.bufferSize = n }
+}
+
+// DefaultInboxSize is how many received messages can wait in memory for a full Messages
+// channel unless WithInboxSize says otherwise.
+const DefaultInboxSize = 10000
+
+// WithInboxSize sets how many received messages can wait in memory while the Messages
+// channel is full. Each waiting message costs about as much memory as its text. If one more
+// arrives, the client ends the subscription with a ResourceExhausted status, as the server
+// does with clients that cannot keep up. With WithReconnect it then subscribes again,
+// resuming after the last message it read, while the application catches up.
+// Defaults to DefaultInboxSize.
+func WithInboxSize(n int) Option {
+	return func(c *Client) { c.inboxSize = n }
+}
+
+// WithClock chooses the logical clock of the client. The Lamport clock is always kept for
+// the timestamp of every message; choosing a vector or hybrid clock keeps that clock as well
+// and attaches its time to everything the client sends.
+func WithClock(kind clock.Kind) Option {
+	return func(c *Client) { c.clockKind = kind }
+}
+
+// WithCausalDelivery makes the client hold back chat messages until every message they
+// causally depend on has been delivered. If a message waits longer than timeout, it is
+// delivered anyway with Forced set and a warning is logged. It needs vector clocks, so it
+// must be combined with WithClock(clock.KindVector).
+func WithCausalDelivery(timeout time.Duration) Option {
+	return func(c *Client) { c.causal = timeout }
+}
+
+// WithKeepalive sets the gRPC keepalive parameters of the connection, so gRPC itself notices
+// a connection that has gone silent. The server disconnects clients that ping too often,
+// so params.Time should not be shorter than what the server allows.
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return func(c *Client) { c.dialOptions = append(c.dialOptions, grpc.WithKeepaliveParams(params)) }
+}
+
+// WithHeartbeat makes the client ping the server on the Chat stream once per interval.
+// If the client hears nothing from the server for misses intervals in a row, it reports
+// the server as unreachable: the client reconnects if it was made with WithReconnect, otherwise
+// the subscription ends and Err returns an Unavailable status.
+// It has no effect on servers without the Chat stream.
+func WithHeartbeat(interval time.Duration, misses int) Option {
+	return func(c *Client) {
+		c.heartbeat = interval
+		c.misses = misses
+	}
+}
+
+// WithResume resumes a subscription that was cut off after the message with sequence number
+// lastSequence, as returned by LastSequence of the old client. The server first sends the
+// messages missed since then, and if the old subscription ended recently enough, puts the
+// client back in its rooms without announcing that it left and joined again.
+func WithResume(lastSequence int64) Option {
+	return func(c *Client) { c.resumeFrom = lastSequence }
+}
+
+// OnJoin registers a function that is called for every join message the client gets, with
+// the user and the room: when a user subscribes, with the room it starts in, and when it
+// joins another room the client is in. It is called from the receiving goroutine, so it
+// should not block.
+func OnJoin(fn func(name, room string)) Option {
+	return func(c *Client) { c.onJoin = fn }
+}
+
+// OnLeave registers a function that is called for every leave message the client gets, with
+// the user and the room: when a user leaves a room the client is in, and when it leaves the
+// server, once for every room they were both in. It is called from the receiving goroutine,
+// so it should not block.
+func OnLeave(fn func(name, room string)) Option {
+	return func(c *Client) { c.onLeave = fn }
+}
+
+// Dial connects to the server at addr and subscribes to it.
+// It blocks until the connection is ready or ctx is done.
+func Dial(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	c := &Client{
+		name:       "default",
+		logger:     log.Default(),
+		bufferSize: 100,
+		inboxSize:  DefaultInboxSize,
+		clockKind:  clock.KindLamport,
+		lamport:    clock.NewLamport(),
+		room:       DefaultRoom,
+		pending:    make(map[int64]chan *gRPC.Envelope),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.rooms = []string{c.room}
+	c.lastSequence.Store(c.resumeFrom)
+	c.received.Store(c.resumeFrom)
+	c.inboxSize = max(c.inboxSize, 1)
+	if c.misses < 1 {
+		c.misses = 1
+	}
+	c.messages = make(chan Message, c.bufferSize)
+	if c.clockKind != clock.KindLamport {
+		logical, err := clock.New(c.clockKind, c.name)
+		if err != nil {
+			return nil, err
+		}
+		c.logical = logical
+	}
+	if c.causal > 0 {
+		if c.clockKind != clock.KindVector {
+			return nil, errors.New("causal delivery needs vector clocks")
+		}
+		c.holdBack = newHoldBack(c.causal, c.name, c.Rooms, c.logger)
+	}
+
+	//without WithTLS the server is not using TLS, so we use insecure credentials
+	//(should be fine for local testing but not in the real world)
+	creds := insecure.NewCredentials()
+	if c.tlsConfig != nil {
+		creds = credentials.NewTLS(c.tlsConfig)
+	}
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(creds),
+	}, c.dialOptions...)
+
+	c.logger.Printf("client %s: Attempts to dial %s\n", c.name, addr)
+	conn, err := grpc.DialContext(ctx, addr, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	c.conn = conn
+	c.server = gRPC.NewChittyChatClient(conn)
+	c.logger.Println("the connection is: ", conn.GetState().String())
+
+	if c.password != "" {
+		if err := c.login(ctx, c.register); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("log in: %w", err)
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	l, err := c.connect(subCtx)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+	go c.subscribe(subCtx, l)
+	return c, nil
+}
+
+// Name returns the name the client subscribed with.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Now returns the current Lamport time of the client.
+func (c *Client) Now() int64 {
+	return c.lamport.Now().Counter
+}
+
+// Ready reports whether the connection to the server is ready. See State for whether
+// the client is subscribed over it.
+func (c *Client) Ready() bool {
+	return c.conn.GetState() == connectivity.Ready
+}
+
+// LastSequence returns the sequence number of the last message received from the server,
+// to resume from with WithResume if the subscription is cut off.
+func (c *Client) LastSequence() int64 {
+	return c.lastSequence.Load()
+}
+
+// Messages returns the channel every received message is delivered on.
+// It is closed when the subscription ends; Err then tells why.
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Err returns the error that ended the subscription, or nil if it is still running
+// or ended because of Close. While reconnecting the subscription counts as running.
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Room returns the room Send sends to.
+func (c *Client) Room() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.room
+}
+
+// Rooms returns the rooms the client is in, sorted.
+func (c *Client) Rooms() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.rooms...)
+}
+
+// NewMessageID returns a random id for SendWithID and SendDirectWithID.
+func NewMessageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Send publishes text to everyone in the client's current room.
+func (c *Client) Send(ctx context.Context, text string) error {
+	_, err := c.SendWithID(ctx, NewMessageID(), text)
+	return err
+}
+
+// SendWithID publishes text to everyone in the client's current room, with id as its message
+// id, and returns the sequence number it was broadcast with. If it is not known whether the
+// message got through, e.g. because the call timed out, it can be sent again with the same
+// id: a server that already broadcast it answers with the first sequence number instead
+// of broadcasting it twice.
+func (c *Client) SendWithID(ctx context.Context, id, text string) (int64, error) {
+	room := c.Room()
+	return c.publish(ctx, &gRPC.ChatMessage{
+		ClientName: c.name,
+		Timestamp:  c.lamport.Tick().Counter,
+		Message:    text,
+		Clock:      c.tickClock(clock.RoomEntry(c.name, room)),
+		Room:       room,
+		MessageId:  id,
+	})
+}
+
+// SendDirect sends text privately to the client called to. It fails with a NotFound
+// status if that client is not connected.
+func (c *Client) SendDirect(ctx context.Context, to, text string) error {
+	_, err := c.SendDirectWithID(ctx, NewMessageID(), to, text)
+	return err
+}
+
+// SendDirectWithID is SendDirect with a message id, which makes it safe to send again
+// like SendWithID. It returns the sequence number the message was sent with.
+func (c *Client) SendDirectWithID(ctx context.Context, id, to, text string) (int64, error) {
+	if to == "" {
+		// it would go to the room instead
+		return 0, status.Error(codes.InvalidArgument, "a direct message needs a recipient")
+	}
+	return c.publish(ctx, &gRPC.ChatMessage{
+		ClientName: c.name,
+		Timestamp:  c.lamport.Tick().Counter,
+		Message:    text,
+		Clock:      c.tickClock(clock.DirectEntry(c.name, to)),
+		Recipient:  to,
+		MessageId:  id,
+	})
+}
+
+// publish sends message on the Chat stream, or with Publish or SendDirect if the server
+// does not have Chat, and moves the clocks past the time the server accepted it.
+// It fails with an Unavailable status while the client is reconnecting.
+func (c *Client) publish(ctx context.Context, message *gRPC.ChatMessage) (int64, error) {
+	if !utf8.ValidString(message.Message) {
+		// gRPC could not even send it
+		return 0, status.Error(codes.InvalidArgument, "the message is not valid UTF-8")
+	}
+	l, err := c.current()
+	if err != nil {
+		return 0, err
+	}
+	var ack *gRPC.ChatAccept
+	switch {
+	case l.chat != nil:
+		ack, err = c.publishChat(ctx, l, message)
+	case message.Recipient != "":
+		ack, err = c.server.SendDirect(ctx, message)
+	default:
+		ack, err = c.server.Publish(ctx, message)
+	}
+	if err != nil {
+		return 0, err
+	}
+	c.lamport.Observe(clock.LamportTime(ack.Timestamp))
+	c.observeClock(ack.Clock)
+	return ack.Sequence, nil
+}
+
+// JoinRoom joins room, if the client was not in it already, and makes it the room Send sends to.
+func (c *Client) JoinRoom(ctx context.Context, room string) error {
+	reply, err := c.server.JoinRoom(ctx, &gRPC.RoomRequest{ClientName: c.name, Room: room, Timestamp: c.lamport.Tick().Counter})
+	if err != nil {
+		return err
+	}
+	c.lamport.Observe(clock.LamportTime(reply.Timestamp))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.room = reply.Room
+	i := sort.SearchStrings(c.rooms, reply.Room)
+	if i == len(c.rooms) || c.rooms[i] != reply.Room {
+		c.rooms = append(c.rooms[:i], append([]string{reply.Room}, c.rooms[i:]...)...)
+	}
+	return nil
+}
+
+// LeaveRoom leaves room. If it was the room Send sends to, Send switches to one of the
+// other rooms the client is in, or fails if there are none left.
+func (c *Client) LeaveRoom(ctx context.Context, room string) error {
+	reply, err := c.server.LeaveRoom(ctx, &gRPC.RoomRequest{ClientName: c.name, Room: room, Timestamp: c.lamport.Tick().Counter})
+	if err != nil {
+		return err
+	}
+	c.lamport.Observe(clock.LamportTime(reply.Timestamp))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	i := sort.SearchStrings(c.rooms, reply.Room)
+	if i < len(c.rooms) && c.rooms[i] == reply.Room {
+		c.rooms = append(c.rooms[:i], c.rooms[i+1:]...)
+	}
+	if c.room == reply.Room {
+		c.room = ""
+		if len(c.rooms) > 0 {
+			c.room = c.rooms[0]
+		}
+	}
+	return nil
+}
+
+// ListRooms returns the rooms on the server and who is in them, sorted by name.
+func (c *Client) ListRooms(ctx context.Context) ([]Room, error) {
+	list, err := c.server.ListRooms(ctx, &gRPC.ListRoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]Room, len(list.Rooms))
+	for i, info := range list.Rooms {
+		rooms[i] = Room{Name: info.Name, Members: info.Members}
+	}
+	return rooms, nil
+}
+
+// HistoryQuery selects a page of past messages for History. Fields that are 0 are not used.
+// If only the After fields are set, the page holds the oldest messages after them.
+// Otherwise it holds the newest messages before the Before fields.
+type HistoryQuery struct {
+	BeforeSequence  int64
+	AfterSequence   int64
+	BeforeTimestamp int64  // Lamport time
+	AfterTimestamp  int64  // Lamport time
+	Limit           int    // most messages in the page, 0 for the server's default
+	Room            string // only messages of this room and messages sent to everyone, all rooms if empty
+}
+
+// History fetches one page of past messages, oldest first, and reports whether there
+// are more in the direction the page was read. The messages only have LocalTime 0,
+// since they were not received by this client when they were sent.
+func (c *Client) History(ctx context.Context, q HistoryQuery) ([]Message, bool, error) {
+	page, err := c.server.History(ctx, &gRPC.HistoryRequest{
+		BeforeSequence:  q.BeforeSequence,
+		AfterSequence:   q.AfterSequence,
+		BeforeTimestamp: q.BeforeTimestamp,
+		AfterTimestamp:  q.AfterTimestamp,
+		Limit:           int32(q.Limit),
+		Room:            q.Room,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	msgs := make([]Message, len(page.Messages))
+	for i, res := range page.Messages {
+		sent, _ := clock.Decode(res.Clock)
+		msgs[i] = Message{
+			ClientName: res.ClientName,
+			Text:       res.Message,
+			Kind:       res.Kind,
+			Room:       res.Room,
+			Sequence:   res.Sequence,
+			Timestamp:  res.Timestamp,
+			ID:         res.MessageId,
+			Account:    res.Account,
+			Clock:      sent,
+		}
+	}
+	return msgs, page.More, nil
+}
+
+// LastMessages fetches up to n of the newest messages of room sent before the message with
+// sequence number before, oldest first. If before is 0 it fetches the newest messages there are.
+func (c *Client) LastMessages(ctx context.Context, room string, n int, before int64) ([]Message, error) {
+	var msgs []Message
+	for len(msgs) < n {
+		page, more, err := c.History(ctx, HistoryQuery{BeforeSequence: before, Limit: n - len(msgs), Room: room})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		msgs = append(page, msgs...)
+		before = page[0].Sequence
+		if !more {
+			break
+		}
+	}
+	return msgs, nil
+}
+
+// Close unsubscribes and closes the connection to the server.
+func (c *Client) Close() error {
+	if l, err := c.current(); err == nil && l.chat != nil {
+		// say goodbye, so the server does not have to wait for the connection to drop
+		l.send(&gRPC.Envelope{Body: &gRPC.Envelope_Leave{Leave: &gRPC.Leave{Reason: "client closed"}}})
+		l.sendMutex.Lock()
+		l.chat.CloseSend()
+		l.sendMutex.Unlock()
+	}
+	c.cancel()
+	return c.conn.Close()
+}
+
+// subscribe delivers the messages read from l, and from the links that replace it when
+// the client reconnects, until the subscription ends.
+func (c *Client) subscribe(ctx context.Context, l *link) {
+	defer close(c.messages)
+	in := newInbox(c.inboxSize)
+	go c.receive(ctx, l, in)
+
+	// held back messages are checked for expiry a few times per timeout
+	var expire <-chan time.Time
+	if c.holdBack != nil {
+		ticker := time.NewTicker(c.holdBack.timeout / 4)
+		defer ticker.Stop()
+		expire = ticker.C
+	}
+
+	for {
+		var ready []Message
+		open := true
+		select {
+		case <-in.ready:
+			var received []*gRPC.ChatMessage
+			received, open = in.take()
+			for _, res := range received {
+				if res.Sequence <= c.lastSequence.Load() {
+					// replayed again by a resumed subscription, which should not happen, but
+					// the application must not get a message twice
+					continue
+				}
+				msg := c.message(res)
+				if c.holdBack != nil {
+					ready = append(ready, c.holdBack.add(msg, time.Now())...)
+				} else {
+					ready = append(ready, msg)
+				}
+			}
+			if !open && c.holdBack != nil {
+				ready = append(ready, c.holdBack.flush()...)
+			}
+		case now := <-expire:
+			ready = c.holdBack.expire(now)
+		}
+		if !c.deliver(ctx, ready) || !open {
+			return
+		}
+	}
+}
+
+// message turns a received message into a Message and moves the clocks past it.
+func (c *Client) message(res *gRPC.ChatMessage) Message {
+	msg := Message{
+		ClientName: res.ClientName,
+		Text:       res.Message,
+		Kind:       res.Kind,
+		Room:       res.Room,
+		Sequence:   res.Sequence,
+		Timestamp:  res.Timestamp,
+		LocalTime:  c.lamport.Observe(clock.LamportTime(res.Timestamp)).Counter,
+		ID:         res.MessageId,
+		Account:    res.Account,
+		Clock:      c.observeClock(res.Clock),
+	}
+	c.logger.Printf("#%d \"%s\" at timestamp %d", msg.Sequence, msg.Text, msg.LocalTime)
+	if last := c.lastSequence.Load(); last != 0 && msg.Sequence > last+1 {
+		// the server only sends the messages of our rooms and those sent to us, so this is not always a loss
+		c.logger.Printf("client %s: did not get %d messages before #%d, they were dropped or meant for other rooms or users", c.name, msg.Sequence-last-1, msg.Sequence)
+	}
+	c.lastSequence.Store(msg.Sequence)
+	return msg
+}
+
+// receive reads messages from l until it ends, then reconnects if the client was made
+// with WithReconnect and reads from the new link, and so on. Once it stops, it records
+// why the subscription ended.
+func (c *Client) receive(ctx context.Context, l *link, in *inbox) {
+	defer in.close()
+	defer c.setState(StateDisconnected)
+	for {
+		err := c.read(l, in)
+		if ctx.Err() != nil {
+			// closed
+			return
+		}
+		if c.backoff.Initial <= 0 || !retryable(err) {
+			if err != nil {
+				c.mutex.Lock()
+				c.err = err
+				c.mutex.Unlock()
+			}
+			return
+		}
+		if l = c.reconnect(ctx, err); l == nil {
+			return
+		}
+	}
+}
+
+// read reads messages from l into in until it ends, and returns why: nil if the server
+// ended the stream, the heartbeat error if the server stopped answering them.
+// It never waits for the messages to be delivered, see inbox.
+func (c *Client) read(l *link, in *inbox) error {
+	defer l.cancel()
+	defer close(l.done)
+	for {
+		res, err := l.recv()
+		if err != nil {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			if c.link == l {
+				c.link = nil
+			}
+			if l.err != nil {
+				return l.err
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !in.add(res) {
+			return status.Errorf(codes.ResourceExhausted, "%d received messages are waiting for the application to take them", c.inboxSize)
+		}
+		if res.Sequence > c.received.Load() {
+			c.received.Store(res.Sequence)
+		}
+	}
+}
+
+// deliver finds the concurrent messages of msgs, calls the callbacks and puts them on the
+// Messages channel. It returns false if the client was closed meanwhile.
+func (c *Client) deliver(ctx context.Context, msgs []Message) bool {
+	for _, msg := range msgs {
+		if !msg.Clock.IsZero() {
+			for _, r := range c.recent {
+				if r.Clock.Kind == msg.Clock.Kind && msg.Clock.Compare(r.Clock) == clock.Concurrent {
+					msg.ConcurrentWith = append(msg.ConcurrentWith, r.Sequence)
+				}
+			}
+			c.logger.Printf("#%d was sent at %s time %s, concurrent with %v", msg.Sequence, msg.Clock.Kind, msg.Clock, msg.ConcurrentWith)
+			c.recent = append(c.recent, msg)
+			if len(c.recent) > recentClocks {
+				c.recent = c.recent[1:]
+			}
+		}
+
+		switch msg.Kind {
+		case KindJoin:
+			if c.onJoin != nil {
+				c.onJoin(msg.ClientName, msg.Room)
+			}
+		case KindLeave:
+			if c.onLeave != nil {
+				c.onLeave(msg.ClientName, msg.Room)
+			}
+		}
+		select {
+		case c.messages <- msg:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// tickClock counts a send event on the extra clock and returns the encoded time to attach,
+// or nil if the client only uses the Lamport clock. A vector clock counts a chat message
+// on entry, see clock.RoomEntry, and anything else, with an empty entry, on the client's own.
+func (c *Client) tickClock(entry string) []byte {
+	if c.logical == nil {
+		return nil
+	}
+	if vector, ok := c.logical.(*clock.VectorClock); ok && entry != "" {
+		return vector.TickEntry(entry).Encode()
+	}
+	return c.logical.Tick().Encode()
+}
+
+// observeClock decodes a received clock time and moves the extra clock past it, if the
+// client has one. It returns the decoded time, the zero Time if it could not be decoded.
+func (c *Client) observeClock(encoded []byte) clock.Time {
+	sent, err := clock.Decode(encoded)
+	if err != nil {
+		c.logger.Printf("client %s: ignoring clock of received message: %v", c.name, err)
+		return clock.Time{}
+	}
+	if c.logical != nil && !sent.IsZero() {
+		c.logical.Observe(sent)
+	}
+	return sent
+}
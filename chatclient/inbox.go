@@ -0,0 +1,64 @@
+package chatclient
+
+import (
+	"sync"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// inbox hands received messages from the goroutine reading the stream to the one delivering
+// them on Messages. Adding to it never blocks: the reader also handles the acks that Send
+// waits for, so it has to keep reading even while the application is busy with a message,
+// e.g. because it is calling Send from its Messages loop. What it can hold is bounded by
+// size instead, see WithInboxSize. The messages taken out but not delivered yet do not count.
+type inbox struct {
+	mutex  sync.Mutex
+	size   int
+	queued []*gRPC.ChatMessage
+	closed bool
+	// ready has a value in it while there is something to take
+	ready chan struct{}
+}
+
+func newInbox(size int) *inbox {
+	return &inbox{size: size, ready: make(chan struct{}, 1)}
+}
+
+// add queues msg, and returns false without queueing it if the inbox is full.
+func (in *inbox) add(msg *gRPC.ChatMessage) bool {
+	in.mutex.Lock()
+	if len(in.queued) >= in.size {
+		in.mutex.Unlock()
+		return false
+	}
+	in.queued = append(in.queued, msg)
+	in.mutex.Unlock()
+	in.signal()
+	return true
+}
+
+// close says no more messages are coming.
+func (in *inbox) close() {
+	in.mutex.Lock()
+	in.closed = true
+	in.mutex.Unlock()
+	in.signal()
+}
+
+func (in *inbox) signal() {
+	select {
+	case in.ready <- struct{}{}:
+	default:
+		// already signalled, and whoever takes will get this too
+	}
+}
+
+// take returns every queued message, oldest first, and whether more can come.
+// It is called after receiving from ready.
+func (in *inbox) take() ([]*gRPC.ChatMessage, bool) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	queued := in.queued
+	in.queued = nil
+	return queued, !in.closed
+}
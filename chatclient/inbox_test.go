@@ -0,0 +1,33 @@
+package chatclient
+
+import (
+	"testing"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+func TestInbox(t *testing.T) {
+	in := newInbox(2)
+	for i := int64(1); i <= 2; i++ {
+		if !in.add(&gRPC.ChatMessage{Sequence: i}) {
+			t.Fatalf("message %d did not fit", i)
+		}
+	}
+	if in.add(&gRPC.ChatMessage{Sequence: 3}) {
+		t.Fatal("a full inbox took another message")
+	}
+	<-in.ready
+	queued, open := in.take()
+	if len(queued) != 2 || queued[0].Sequence != 1 || queued[1].Sequence != 2 || !open {
+		t.Fatalf("took %v, open %v", queued, open)
+	}
+	// taking makes room
+	if !in.add(&gRPC.ChatMessage{Sequence: 3}) {
+		t.Fatal("the inbox is still full after taking everything")
+	}
+	in.close()
+	<-in.ready
+	if queued, open := in.take(); len(queued) != 1 || open {
+		t.Errorf("after closing took %v, open %v", queued, open)
+	}
+}
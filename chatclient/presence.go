@@ -0,0 +1,116 @@
+package chatclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hannaStokes/handin3/clock"
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// Status is what a user tells others about being available to chat.
+type Status = gRPC.UserStatus
+
+const (
+	StatusAvailable = gRPC.UserStatus_AVAILABLE
+	StatusAway      = gRPC.UserStatus_AWAY
+	StatusBusy      = gRPC.UserStatus_BUSY
+)
+
+// ParseStatus turns "available", "away" or "busy" into a Status.
+func ParseStatus(name string) (Status, error) {
+	if v, ok := gRPC.UserStatus_value[strings.ToUpper(name)]; ok {
+		return Status(v), nil
+	}
+	return 0, fmt.Errorf("unknown status %q, use available, away or busy", name)
+}
+
+// User is a user connected to the server.
+type User struct {
+	Name        string
+	Connected   time.Time // when the oldest open subscription of the user started
+	JoinedAt    int64     // Lamport timestamp of the join message of that subscription
+	Status      Status
+	Connections int // how many times the user is subscribed
+}
+
+func userFromInfo(info *gRPC.UserInfo) User {
+	return User{
+		Name:        info.ClientName,
+		Connected:   time.Unix(0, info.ConnectedUnixNano),
+		JoinedAt:    info.JoinTimestamp,
+		Status:      info.Status,
+		Connections: int(info.Connections),
+	}
+}
+
+// PresenceChange tells what a PresenceUpdate is about.
+type PresenceChange = gRPC.PresenceUpdate_Change
+
+const (
+	PresenceOnline  = gRPC.PresenceUpdate_ONLINE
+	PresenceOffline = gRPC.PresenceUpdate_OFFLINE
+	PresenceStatus  = gRPC.PresenceUpdate_STATUS
+)
+
+// PresenceUpdate is sent by WatchPresence when a user connects, disconnects or changes its status.
+type PresenceUpdate struct {
+	Change    PresenceChange
+	User      User
+	Timestamp int64 // Lamport time of the server when it happened
+}
+
+// ListUsers returns the users connected to the server, sorted by name.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	list, err := c.server.ListUsers(ctx, &gRPC.ListUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, len(list.Users))
+	for i, info := range list.Users {
+		users[i] = userFromInfo(info)
+	}
+	return users, nil
+}
+
+// SetStatus changes the status other users see for this client.
+func (c *Client) SetStatus(ctx context.Context, status Status) error {
+	info, err := c.server.SetStatus(ctx, &gRPC.StatusRequest{ClientName: c.name, Status: status, Timestamp: c.lamport.Tick().Counter})
+	if err != nil {
+		return err
+	}
+	c.logger.Printf("client %s: status is now %s", c.name, info.Status)
+	return nil
+}
+
+// WatchPresence starts following who is connected. The returned channel first gets a
+// PresenceOnline update for every user that is already connected, then an update for
+// every change, until ctx is done or the stream fails. It is closed when the stream ends.
+func (c *Client) WatchPresence(ctx context.Context) (<-chan PresenceUpdate, error) {
+	stream, err := c.server.WatchPresence(ctx, &gRPC.PresenceRequest{})
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan PresenceUpdate, c.bufferSize)
+	go func() {
+		defer close(updates)
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					c.logger.Printf("client %s: presence stream ended: %v", c.name, err)
+				}
+				return
+			}
+			c.lamport.Observe(clock.LamportTime(res.Timestamp))
+			select {
+			case updates <- PresenceUpdate{Change: res.Change, User: userFromInfo(res.User), Timestamp: res.Timestamp}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
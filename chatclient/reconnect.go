@@ -0,0 +1,231 @@
+package chatclient
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// link is one subscription to the server. A client has one at a time; when it breaks
+// and the client reconnects, a new link takes its place.
+type link struct {
+	// the Chat stream, see chat.go. It is nil if the server is too old to have Chat,
+	// then the client uses Subscribe, Publish and SendDirect instead.
+	chat      gRPC.ChittyChat_ChatClient
+	sendMutex sync.Mutex   // held while sending on chat
+	lastHeard atomic.Int64 // when anything was last read from chat, in Unix nanoseconds
+
+	recv   func() (*gRPC.ChatMessage, error) // reads the next message, from chat or the Subscribe stream
+	cancel context.CancelFunc                // ends the streams of the link
+	done   chan struct{}                     // closed once nothing more is read from it
+	err    error                             // set by heartbeats when the server stopped answering. Guarded by the client's mutex
+}
+
+// State tells whether a client is subscribed, see OnStateChange.
+type State int
+
+const (
+	StateConnected    State = iota
+	StateReconnecting       // the subscription broke and the client is trying to get it back
+	StateDisconnected       // the subscription ended for good, Err tells why
+)
+
+var stateNames = map[State]string{
+	StateConnected:    "connected",
+	StateReconnecting: "reconnecting",
+	StateDisconnected: "disconnected",
+}
+
+func (st State) String() string {
+	if name, ok := stateNames[st]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Backoff says how long a client waits between attempts to reconnect. The first attempt
+// is made after about Initial, and every failed attempt doubles the wait up to Max.
+// Each wait is picked at random between half of it and all of it, so clients that lost
+// the server at the same time do not all come back at the same moment.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// wait returns how long to wait before reconnect attempt n, counting from 0.
+func (b Backoff) wait(n int) time.Duration {
+	d := b.Initial
+	for i := 0; i < n && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// WithReconnect makes the client subscribe again when its subscription breaks, instead of
+// ending it, waiting between attempts as b says. It resumes from the last received message,
+// so the messages sent meanwhile are delivered too, and rejoins the client's rooms.
+// It keeps trying until the server refuses the subscription or the client is closed.
+// While reconnecting, Send and SendDirect fail with an Unavailable status.
+func WithReconnect(b Backoff) Option {
+	return func(c *Client) {
+		if b.Max < b.Initial {
+			b.Max = b.Initial
+		}
+		c.backoff = b
+	}
+}
+
+// OnStateChange registers a function that is called whenever the client loses the
+// subscription, gets it back, or gives up. It is called from the receiving goroutine,
+// so it should not block.
+func OnStateChange(fn func(State)) Option {
+	return func(c *Client) { c.onState = fn }
+}
+
+// State tells whether the client is subscribed right now.
+func (c *Client) State() State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state
+}
+
+func (c *Client) setState(st State) {
+	c.mutex.Lock()
+	changed := c.state != st
+	c.state = st
+	c.mutex.Unlock()
+	if changed && c.onState != nil {
+		c.onState(st)
+	}
+}
+
+// current returns the current link, or an Unavailable status if the client is reconnecting.
+func (c *Client) current() (*link, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.link == nil {
+		return nil, status.Error(codes.Unavailable, "not connected to the server")
+	}
+	return c.link, nil
+}
+
+// connect subscribes on the Chat stream, or with Subscribe if the server does not have it,
+// resuming after the last message read, and makes that the current link. Messages still
+// in the inbox are not sent again.
+// The link lasts until ctx is done or it breaks.
+func (c *Client) connect(ctx context.Context) (*link, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &link{cancel: cancel, done: make(chan struct{})}
+	hello := &gRPC.SubMessage{
+		ClientName:   c.name,
+		Timestamp:    c.lamport.Tick().Counter,
+		Clock:        c.tickClock(""),
+		Room:         c.Room(),
+		LastSequence: c.received.Load(),
+	}
+	if hello.LastSequence > 0 {
+		// so the server replays what we missed in all of them
+		for _, room := range c.Rooms() {
+			if room != hello.Room {
+				hello.Rooms = append(hello.Rooms, room)
+			}
+		}
+	}
+	err := c.startChat(ctx, l, hello)
+	if status.Code(err) == codes.Unimplemented {
+		c.logger.Printf("client %s: the server has no Chat stream, using Subscribe and Publish", c.name)
+		var stream gRPC.ChittyChat_SubscribeClient
+		stream, err = c.server.Subscribe(ctx, hello)
+		l.recv = func() (*gRPC.ChatMessage, error) { return stream.Recv() }
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c.mutex.Lock()
+	c.link = l
+	c.mutex.Unlock()
+	if l.chat != nil && c.heartbeat > 0 {
+		go c.heartbeats(ctx, l)
+	}
+	return l, nil
+}
+
+// reconnect subscribes again after the link broke because of cause, waiting longer after
+// every failed attempt. It returns the new link, or nil if the client was closed or the
+// server refused the subscription, then with c.err set.
+func (c *Client) reconnect(ctx context.Context, cause error) *link {
+	c.setState(StateReconnecting)
+	c.logger.Printf("client %s: lost the subscription (%v), reconnecting", c.name, cause)
+	for attempt := 0; ; attempt++ {
+		wait := c.backoff.wait(attempt)
+		c.logger.Printf("client %s: reconnect attempt %d in %s", c.name, attempt+1, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil
+		}
+		l, err := c.connect(ctx)
+		if status.Code(err) == codes.Unauthenticated && c.password != "" {
+			// the server forgot our session, e.g. because it restarted
+			c.logger.Printf("client %s: logging in again: %v", c.name, err)
+			if err = c.login(ctx, false); err == nil {
+				l, err = c.connect(ctx)
+			}
+		}
+		if err == nil {
+			c.logger.Printf("client %s: reconnected after message #%d", c.name, c.received.Load())
+			c.rejoin(ctx)
+			c.setState(StateConnected)
+			return l
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !retryable(err) {
+			c.logger.Printf("client %s: the server refused to subscribe us again: %v", c.name, err)
+			c.mutex.Lock()
+			c.err = err
+			c.mutex.Unlock()
+			return nil
+		}
+		c.logger.Printf("client %s: reconnect attempt %d failed: %v", c.name, attempt+1, err)
+	}
+}
+
+// rejoin joins the rooms the client was in again. The server already puts a resumed
+// subscription back in the rooms in its hello, but servers without that do not, nor does
+// any server when there are too many.
+func (c *Client) rejoin(ctx context.Context) {
+	current := c.Room()
+	for _, room := range c.Rooms() {
+		if room == current {
+			// the subscription started there
+			continue
+		}
+		if _, err := c.server.JoinRoom(ctx, &gRPC.RoomRequest{ClientName: c.name, Room: room, Timestamp: c.lamport.Tick().Counter}); err != nil {
+			c.logger.Printf("client %s: could not rejoin room %s: %v", c.name, room, err)
+		}
+	}
+}
+
+// retryable reports whether a subscription that ended with err is worth trying again.
+// nil means the server ended it, e.g. because it was shutting down.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.AlreadyExists, codes.NotFound,
+		codes.PermissionDenied, codes.Unauthenticated, codes.Unimplemented:
+		return false
+	}
+	return true
+}
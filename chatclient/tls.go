@@ -0,0 +1,62 @@
+package chatclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ClientTLS makes the TLS config for WithTLS. caFile has the CA certificates the server
+// certificate has to be signed by, or is empty to trust the CAs of the system. certFile
+// and keyFile are the client certificate for servers that use mutual TLS, and can be
+// left empty for servers that do not. serverName is the name the server certificate has
+// to be for, if it is not the host in the address the client dials.
+func ClientTLS(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s has no PEM certificates in it", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("a client certificate needs both the certificate and its key")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// CertName returns the common name (CN) of the client certificate in config, which
+// servers with mutual TLS can take as the client name. It is empty without one.
+func CertName(config *tls.Config) string {
+	if len(config.Certificates) == 0 {
+		return ""
+	}
+	cert := config.Certificates[0]
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return ""
+		}
+		return leaf.Subject.CommonName
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+// WithTLS makes the client connect with TLS, set up by config (see ClientTLS).
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) { c.tlsConfig = config }
+}
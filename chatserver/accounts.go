@@ -0,0 +1,320 @@
+package chatserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Passwords have to be at least minPassword and at most maxPassword bytes long.
+const (
+	minPassword = 8
+	maxPassword = 1024
+)
+
+// DefaultSessionTTL is how long a session lasts without being used, unless OpenAccounts is told otherwise.
+const DefaultSessionTTL = 24 * time.Hour
+
+// Accounts are the client names that have been registered, with their password hashes,
+// and the sessions of the clients logged in to them. It is safe for concurrent use.
+//
+// The accounts are kept in a file with a name and its password hash on every line, which
+// only ever has lines added to it. Sessions are only kept in memory, so clients have to
+// log in again after the server restarts.
+//
+// When clients can also log in some other way, through AnyAuth, the names they log in
+// as must not be registered by someone else, or whoever does gets to use them too. Reserve
+// the names of TokenAuth for that. The names in client certificates cannot be known in
+// advance, so Accounts should not be used together with CertAuth unless the CA only signs
+// names that Register refuses anyway.
+type Accounts struct {
+	mutex    sync.Mutex
+	file     *os.File // nil to keep the accounts only in memory
+	hashes   map[string]string
+	reserved map[string]bool              // names that belong to clients logging in some other way
+	sessions map[[sha256.Size]byte]*login // by the hash of the token
+	ttl      time.Duration
+
+	// hashing limits how many passwords are hashed at once, as every hash takes a lot of
+	// CPU on purpose. Logins past that wait their turn instead of slowing down the chat.
+	hashing chan struct{}
+	// decoy is checked against for names without an account, so a login as an unknown
+	// name takes as long as one with a wrong password
+	decoyOnce sync.Once
+	decoy     string
+}
+
+// login is a session of a client logged in to an account.
+type login struct {
+	name    string
+	expires time.Time
+}
+
+// OpenAccounts reads the accounts in the file at path, creating it if it does not exist,
+// and adds new ones to it. With an empty path, accounts are only kept in memory.
+// Sessions end after ttl without being used, or DefaultSessionTTL if ttl is 0.
+func OpenAccounts(path string, ttl time.Duration) (*Accounts, error) {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	a := &Accounts{
+		hashes:   make(map[string]string),
+		reserved: make(map[string]bool),
+		sessions: make(map[[sha256.Size]byte]*login),
+		ttl:      ttl,
+		hashing:  make(chan struct{}, runtime.NumCPU()),
+	}
+	if path == "" {
+		return a, nil
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open accounts: %w", err)
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: expected a name and a password hash", path, line)
+		}
+		if _, ok := a.hashes[fields[0]]; ok {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %s is registered already", path, line, fields[0])
+		}
+		a.hashes[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("read accounts %s: %w", path, err)
+	}
+	a.file = file
+	return a, nil
+}
+
+// Len returns the number of accounts.
+func (a *Accounts) Len() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.hashes)
+}
+
+// Reserve keeps names from being registered, because they belong to clients that log in
+// some other way, e.g. with TokenAuth. It fails if one of them has an account already.
+func (a *Accounts) Reserve(names ...string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, name := range names {
+		if _, ok := a.hashes[name]; ok {
+			return fmt.Errorf("%s cannot be reserved, it is registered already", name)
+		}
+	}
+	for _, name := range names {
+		a.reserved[name] = true
+	}
+	return nil
+}
+
+// Close closes the accounts file.
+func (a *Accounts) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// Register makes an account for name with password. It fails with AlreadyExists if
+// name has one already or is reserved, or InvalidArgument if name or password cannot be used.
+func (a *Accounts) Register(ctx context.Context, name, password string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkPasswordLength(password); err != nil {
+		return err
+	}
+	if a.taken(name) {
+		return status.Errorf(codes.AlreadyExists, "the name %s is taken", name)
+	}
+	if err := a.startHashing(ctx); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	a.stopHashing()
+	if err != nil {
+		return status.Errorf(codes.Internal, "hash password: %v", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	// someone else may have been quicker while the password was hashed
+	if _, ok := a.hashes[name]; ok || a.reserved[name] {
+		return status.Errorf(codes.AlreadyExists, "the name %s is taken", name)
+	}
+	if a.file != nil {
+		if _, err := fmt.Fprintf(a.file, "%s %s\n", name, hash); err != nil {
+			return status.Errorf(codes.Internal, "save account: %v", err)
+		}
+		if err := a.file.Sync(); err != nil {
+			return status.Errorf(codes.Internal, "save account: %v", err)
+		}
+	}
+	a.hashes[name] = hash
+	return nil
+}
+
+// Login checks the password of the account name and starts a session for it. It returns
+// the token of the session and when it ends if it is not used, or Unauthenticated if
+// there is no such account or the password is wrong, without saying which.
+func (a *Accounts) Login(ctx context.Context, name, password string) (string, time.Time, error) {
+	a.mutex.Lock()
+	hash, ok := a.hashes[name]
+	a.mutex.Unlock()
+	if !ok {
+		a.decoyOnce.Do(func() { a.decoy, _ = hashPassword("not anyone's password") })
+		hash = a.decoy
+	}
+
+	if err := a.startHashing(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+	right, err := checkPassword(hash, password)
+	a.stopHashing()
+	if err != nil {
+		return "", time.Time{}, status.Errorf(codes.Internal, "the stored password of %s is broken: %v", name, err)
+	}
+	if !ok || !right {
+		return "", time.Time{}, status.Error(codes.Unauthenticated, "wrong name or password")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, status.Errorf(codes.Internal, "make session token: %v", err)
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	session := &login{name: name, expires: now.Add(a.ttl)}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key, other := range a.sessions {
+		if now.After(other.expires) {
+			delete(a.sessions, key)
+		}
+	}
+	a.sessions[sha256.Sum256([]byte(token))] = session
+	return token, session.expires, nil
+}
+
+// Auth returns an AuthFunc that takes the session tokens Login hands out, from the same
+// "authorization: Bearer <token>" metadata as TokenAuth. Every use makes the session last longer.
+func (a *Accounts) Auth() AuthFunc {
+	return func(ctx context.Context) (string, error) {
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		key := sha256.Sum256([]byte(token))
+		now := time.Now()
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		session, ok := a.sessions[key]
+		if !ok || now.After(session.expires) {
+			delete(a.sessions, key)
+			return "", status.Error(codes.Unauthenticated, "no such session, log in again")
+		}
+		session.expires = now.Add(a.ttl)
+		return session.name, nil
+	}
+}
+
+// taken reports whether name has an account or is reserved.
+func (a *Accounts) taken(name string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, ok := a.hashes[name]
+	return ok || a.reserved[name]
+}
+
+// startHashing waits until a password can be hashed. Every call has to be followed by stopHashing.
+func (a *Accounts) startHashing(ctx context.Context) error {
+	select {
+	case a.hashing <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (a *Accounts) stopHashing() {
+	<-a.hashing
+}
+
+// checkPasswordLength makes sure a new password is neither too easy to guess for its
+// length nor so long that hashing it is a waste.
+func checkPasswordLength(password string) error {
+	switch {
+	case len(password) < minPassword:
+		return invalid("password", fmt.Sprintf("the password has to be at least %d characters", minPassword))
+	case len(password) > maxPassword:
+		return invalid("password", fmt.Sprintf("the password can be at most %d characters", maxPassword))
+	}
+	return nil
+}
+
+// Register makes an account, and logs in to it. Anyone can call it, and every account costs
+// a password hash and a write to the accounts file, so it counts against the rate limit of
+// the caller's address like a message does.
+func (s *Server) Register(ctx context.Context, in *gRPC.Credentials) (*gRPC.Session, error) {
+	if s.accounts == nil {
+		return nil, status.Error(codes.Unimplemented, "this server has no accounts")
+	}
+	var address string
+	if p, ok := peer.FromContext(ctx); ok {
+		address = peerAddress(p.Addr)
+	}
+	allowed := make(chan error, 1)
+	if !s.submit(registerEvent{address: address, allowed: allowed}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	if err := <-allowed; err != nil {
+		return nil, err
+	}
+	if err := s.accounts.Register(ctx, in.ClientName, in.Password); err != nil {
+		s.logger.Printf("Server %s: Could not register %q: %v", s.name, in.ClientName, err)
+		return nil, err
+	}
+	s.logger.Printf("Server %s: Registered the account %s", s.name, in.ClientName)
+	return s.Login(ctx, in)
+}
+
+// Login starts a session for an account.
+func (s *Server) Login(ctx context.Context, in *gRPC.Credentials) (*gRPC.Session, error) {
+	if s.accounts == nil {
+		return nil, status.Error(codes.Unimplemented, "this server has no accounts")
+	}
+	token, expires, err := s.accounts.Login(ctx, in.ClientName, in.Password)
+	if err != nil {
+		s.logger.Printf("Server %s: Refused a login as %q: %v", s.name, in.ClientName, err)
+		return nil, err
+	}
+	s.logger.Printf("User %s logged in", in.ClientName)
+	return &gRPC.Session{ClientName: in.ClientName, Token: token, ExpiresUnixNano: expires.UnixNano()}, nil
+}
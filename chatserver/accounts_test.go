@@ -0,0 +1,159 @@
+package chatserver
+
+import (
+	"context"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The PBKDF2-HMAC-SHA256 test vectors of RFC 7914, section 11.
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(test.password), []byte(test.salt), test.iterations, len(test.want)/2))
+		if got != test.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", test.password, test.salt, test.iterations, got, test.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") {
+		t.Errorf("hash %s is not in the stored form", hash)
+	}
+	if other, _ := hashPassword("correct horse"); other == hash {
+		t.Error("two hashes of the same password are the same, the salt is not random")
+	}
+	if ok, err := checkPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("the right password does not match: %v %v", ok, err)
+	}
+	if ok, err := checkPassword(hash, "correct horsf"); ok || err != nil {
+		t.Errorf("a wrong password matches: %v %v", ok, err)
+	}
+	for _, broken := range []string{"", "md5$1$x$y", "pbkdf2-sha256$0$c2FsdA$aGFzaA", "pbkdf2-sha256$1$!!$aGFzaA", "pbkdf2-sha256$1$c2FsdA$"} {
+		if _, err := checkPassword(broken, "x"); err == nil {
+			t.Errorf("checkPassword(%q) did not fail", broken)
+		}
+	}
+}
+
+// withToken returns a context with the token in it, as a call from a client with it would have.
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestAccounts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "accounts")
+	a, err := OpenAccounts(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reserve("tokenholder"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, password string
+		code           codes.Code
+	}{
+		{"alice", "long enough", codes.OK},
+		{"alice", "another one", codes.AlreadyExists},
+		{"tokenholder", "long enough", codes.AlreadyExists},
+		{"bob", "short", codes.InvalidArgument},
+		{"b o b", "long enough", codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		if err := a.Register(ctx, test.name, test.password); status.Code(err) != test.code {
+			t.Errorf("Register(%q, %q) = %v, want %s", test.name, test.password, err, test.code)
+		}
+	}
+
+	if _, _, err := a.Login(ctx, "alice", "wrong password"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("login with a wrong password: %v", err)
+	}
+	if _, _, err := a.Login(ctx, "nobody", "long enough"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("login to no account: %v", err)
+	}
+	token, expires, err := a.Login(ctx, "alice", "long enough")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("the session ends in %s, want an hour", d)
+	}
+	auth := a.Auth()
+	if name, err := auth(withToken(token)); name != "alice" || err != nil {
+		t.Errorf("the session is for %q, %v", name, err)
+	}
+	if _, err := auth(withToken("not a token")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("an unknown token: %v", err)
+	}
+	if _, err := auth(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no token: %v", err)
+	}
+	a.Close()
+
+	// the accounts are kept, the sessions are not
+	a, err = OpenAccounts(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if a.Len() != 1 {
+		t.Errorf("%d accounts after opening them again, want 1", a.Len())
+	}
+	if _, err := a.Auth()(withToken(token)); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("an old session still works: %v", err)
+	}
+	if _, _, err := a.Login(ctx, "alice", "long enough"); err != nil {
+		t.Errorf("cannot log in after opening the accounts again: %v", err)
+	}
+	if err := a.Reserve("alice"); err == nil {
+		t.Error("reserved a name that has an account")
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	a, err := OpenAccounts("", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Register(ctx, "alice", "long enough"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := a.Login(ctx, "alice", "long enough")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// using the session keeps it going
+	for i := 0; i < 4; i++ {
+		time.Sleep(150 * time.Millisecond)
+		if _, err := a.Auth()(withToken(token)); err != nil {
+			t.Fatalf("the session ended while it was used: %v", err)
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+	if _, err := a.Auth()(withToken(token)); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("the session did not end: %v", err)
+	}
+}
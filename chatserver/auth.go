@@ -0,0 +1,164 @@
+package chatserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthFunc finds out which client is calling the server, from the request metadata in ctx.
+// It returns the client name the caller is allowed to use, or an error status, usually
+// Unauthenticated, if it cannot tell.
+type AuthFunc func(ctx context.Context) (string, error)
+
+type callerKey struct{}
+
+// caller returns the name the caller of a request was authenticated as, if the server checks that.
+func caller(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(callerKey{}).(string)
+	return name, ok
+}
+
+// checkCaller makes sure a request that says it is from the client called name really is.
+// Without WithAuth every request is believed.
+func (s *Server) checkCaller(ctx context.Context, name string) error {
+	authenticated, ok := caller(ctx)
+	if !ok || authenticated == name {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "logged in as %s, cannot act as %s", authenticated, name)
+}
+
+// authenticate runs the AuthFunc and remembers who the caller is in the returned context.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	name, err := s.auth(ctx)
+	if err != nil {
+		s.logger.Printf("Server %s: Refused a call to %s: %v", s.name, method, err)
+		return nil, err
+	}
+	return context.WithValue(ctx, callerKey{}, name), nil
+}
+
+func (s *Server) authUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if info.FullMethod == gRPC.ChittyChat_Register_FullMethodName || info.FullMethod == gRPC.ChittyChat_Login_FullMethodName {
+		// these are how clients get a session in the first place
+		return handler(ctx, req)
+	}
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticatedStream is a stream whose context knows who the caller is.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (as *authenticatedStream) Context() context.Context {
+	return as.ctx
+}
+
+// bearerToken returns the token in the "authorization: Bearer <token>" metadata of ctx.
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no token, log in first")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || token == "" {
+		return "", status.Error(codes.Unauthenticated, `the authorization metadata has to be "Bearer <token>"`)
+	}
+	return token, nil
+}
+
+// TokenAuth returns an AuthFunc that takes bearer tokens from the "authorization" metadata.
+// tokens holds the token of every client name that can use the server. A token given to
+// more than one name cannot tell who is calling, so it does not let anyone in.
+// LoadTokens refuses files like that.
+func TokenAuth(tokens map[string]string) AuthFunc {
+	// looked up by hash, so how long a lookup takes says nothing about the tokens
+	names := make(map[[sha256.Size]byte]string, len(tokens))
+	shared := make(map[[sha256.Size]byte]bool)
+	for name, token := range tokens {
+		hash := sha256.Sum256([]byte(token))
+		if _, ok := names[hash]; ok {
+			shared[hash] = true
+		}
+		names[hash] = name
+	}
+	for hash := range shared {
+		delete(names, hash)
+	}
+	return func(ctx context.Context) (string, error) {
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		name, ok := names[sha256.Sum256([]byte(token))]
+		if !ok {
+			return "", status.Error(codes.Unauthenticated, "unknown token")
+		}
+		return name, nil
+	}
+}
+
+// LoadTokens reads the tokens for TokenAuth from a file with a client name and its token
+// on every line, separated by spaces. Empty lines and lines starting with # are skipped.
+// Every name and every token can only be in it once.
+func LoadTokens(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tokens := make(map[string]string)
+	owners := make(map[string]string) // who each token belongs to
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a name and a token", path, line)
+		}
+		if err := checkName(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, status.Convert(err).Message())
+		}
+		if _, ok := tokens[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: %s has a token already", path, line, fields[0])
+		}
+		if owner, ok := owners[fields[1]]; ok {
+			return nil, fmt.Errorf("%s:%d: %s has the same token as %s", path, line, fields[0], owner)
+		}
+		tokens[fields[0]] = fields[1]
+		owners[fields[1]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -0,0 +1,173 @@
+package chatserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// withCert returns a context of a client that connected with a verified certificate for name.
+func withCert(name string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+	info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestLoadTokens(t *testing.T) {
+	tests := []struct {
+		name, file, err string
+	}{
+		{"good", "# clients\nalice secret1\n\nbob secret2\n", ""},
+		{"same name twice", "alice secret1\nalice secret2\n", "alice has a token already"},
+		{"same token twice", "alice secret1\nbob secret1\n", "bob has the same token as alice"},
+		{"no token", "alice\n", "expected a name and a token"},
+		{"bad name", "server:x secret1\n", "tokens:1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens")
+			if err := os.WriteFile(path, []byte(test.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := LoadTokens(path)
+			if test.err == "" {
+				if err != nil || len(tokens) != 2 || tokens["bob"] != "secret2" {
+					t.Errorf("got %v, %v", tokens, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got the error %v, want one saying %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestTokenAuth(t *testing.T) {
+	auth := TokenAuth(map[string]string{"alice": "secret1", "bob": "secret2", "carol": "shared", "dave": "shared"})
+	if name, err := auth(withToken("secret2")); err != nil || name != "bob" {
+		t.Errorf("bob's token gave %q, %v", name, err)
+	}
+	contexts := map[string]context.Context{
+		"no token":     context.Background(),
+		"wrong token":  withToken("secret3"),
+		"empty token":  withToken(""),
+		"not bearer":   metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "secret1")),
+		"shared token": withToken("shared"),
+	}
+	for what, ctx := range contexts {
+		if name, err := auth(ctx); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s gave %q, %v, want Unauthenticated", what, name, err)
+		}
+	}
+}
+
+func TestCertAuth(t *testing.T) {
+	auth := CertAuth()
+	if name, err := auth(withCert("alice")); err != nil || name != "alice" {
+		t.Errorf("alice's certificate gave %q, %v", name, err)
+	}
+	contexts := map[string]context.Context{
+		"no peer":           context.Background(),
+		"no TLS":            peer.NewContext(context.Background(), &peer.Peer{}),
+		"no certificate":    peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}}),
+		"unusable name":     withCert("two words"),
+		"server's own name": withCert("server:x"),
+	}
+	for what, ctx := range contexts {
+		if name, err := auth(ctx); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s gave %q, %v, want Unauthenticated", what, name, err)
+		}
+	}
+}
+
+func TestAnyAuth(t *testing.T) {
+	auth := AnyAuth(CertAuth(), TokenAuth(map[string]string{"bob": "secret2"}))
+	for ctx, want := range map[context.Context]string{withCert("alice"): "alice", withToken("secret2"): "bob"} {
+		if name, err := auth(ctx); err != nil || name != want {
+			t.Errorf("got %q, %v, want %s", name, err, want)
+		}
+	}
+	// the error is the token's, the last one tried
+	if _, err := auth(withToken("secret3")); status.Convert(err).Message() != "unknown token" {
+		t.Errorf("a wrong token gave %v", err)
+	}
+	if _, err := AnyAuth()(withToken("secret2")); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no AuthFuncs at all gave %v", err)
+	}
+}
+
+// testStream is a server stream with nothing in it but its context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ts *testStream) Context() context.Context {
+	return ts.ctx
+}
+
+// The interceptors only let in who the AuthFunc knows, and only as themselves.
+func TestAuthInterceptors(t *testing.T) {
+	s := &Server{name: "test", auth: TokenAuth(map[string]string{"alice": "secret1"}), logger: log.New(io.Discard, "", 0)}
+	// a handler acting for the client called name, like every handler does
+	actAs := func(ctx context.Context, name string) error {
+		return s.checkCaller(ctx, name)
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		as   string
+		want codes.Code
+	}{
+		{"right token", withToken("secret1"), "alice", codes.OK},
+		{"someone else's name", withToken("secret1"), "bob", codes.PermissionDenied},
+		{"wrong token", withToken("secret2"), "alice", codes.Unauthenticated},
+		{"no token", context.Background(), "alice", codes.Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unary := &grpc.UnaryServerInfo{FullMethod: gRPC.ChittyChat_Publish_FullMethodName}
+			_, err := s.authUnary(test.ctx, nil, unary, func(ctx context.Context, req any) (any, error) {
+				return nil, actAs(ctx, test.as)
+			})
+			if status.Code(err) != test.want {
+				t.Errorf("unary call: got %v, want %s", err, test.want)
+			}
+
+			stream := &grpc.StreamServerInfo{FullMethod: gRPC.ChittyChat_Chat_FullMethodName}
+			err = s.authStream(nil, &testStream{ctx: test.ctx}, stream, func(srv any, stream grpc.ServerStream) error {
+				return actAs(stream.Context(), test.as)
+			})
+			if status.Code(err) != test.want {
+				t.Errorf("stream: got %v, want %s", err, test.want)
+			}
+		})
+	}
+
+	// logging in is how a client gets a token, so it needs none
+	login := &grpc.UnaryServerInfo{FullMethod: gRPC.ChittyChat_Login_FullMethodName}
+	if _, err := s.authUnary(context.Background(), nil, login, func(ctx context.Context, req any) (any, error) { return nil, nil }); err != nil {
+		t.Errorf("Login without a token: %v", err)
+	}
+	// a call nobody was authenticated for, as without WithAuth, is believed
+	if err := s.checkCaller(context.Background(), "bob"); err != nil {
+		t.Errorf("checkCaller without an authenticated caller: %v", err)
+	}
+}
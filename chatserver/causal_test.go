@@ -0,0 +1,61 @@
+package chatserver_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	"github.com/hannaStokes/handin3/clock"
+)
+
+// A client with causal delivery does not wait for the messages of rooms it is not in,
+// which the vectors of clients that are in them count too.
+func TestCausalDeliveryAcrossRooms(t *testing.T) {
+	addr := startServer(t, chatserver.WithClock(clock.KindVector))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := log.New(io.Discard, "", 0)
+	dial := func(name string, opts ...chatclient.Option) *chatclient.Client {
+		opts = append([]chatclient.Option{chatclient.WithName(name), chatclient.WithLogger(quiet), chatclient.WithClock(clock.KindVector)}, opts...)
+		c, err := chatclient.Dial(ctx, addr, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	b := dial("b")
+	c := dial("c", chatclient.WithCausalDelivery(5*time.Second))
+	d := dial("d", chatclient.WithRoom("music"))
+	for _, other := range []*chatclient.Client{b, d} {
+		go func(other *chatclient.Client) {
+			for range other.Messages() {
+			}
+		}(other)
+	}
+	if err := b.JoinRoom(ctx, "music"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := d.Send(ctx, "only for music"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b now knows of d's messages, which c never gets
+	if err := b.JoinRoom(ctx, chatclient.DefaultRoom); err != nil {
+		t.Fatal(err)
+	}
+	sent := time.Now()
+	if err := b.Send(ctx, "for general"); err != nil {
+		t.Fatal(err)
+	}
+	got := chatUntil(t, ctx, c, "for general")
+	last := got[len(got)-1]
+	if last.Forced || time.Since(sent) > time.Second {
+		t.Errorf("b's message was delivered after %s, forced %v, with clock %s", time.Since(sent).Round(time.Millisecond), last.Forced, last.Clock)
+	}
+}
@@ -0,0 +1,227 @@
+package chatserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chatStream is the server side of a Chat stream. A gRPC stream cannot be sent on from
+// two goroutines at once, and both the session (broadcast messages) and the handler
+// (acks and pings) send on it, so every send goes through the mutex.
+type chatStream struct {
+	mutex  sync.Mutex
+	stream gRPC.ChittyChat_ChatServer
+
+	lastHeard  atomic.Int64  // when anything was last received from the client, in Unix nanoseconds
+	pingWanted atomic.Bool   // a heartbeat is due, and is sent after what is being sent now, see ping
+	closed     chan struct{} // closed when the handler is done, after which the client is not answered any more
+}
+
+// receiveGrace is how long the Chat handler waits for the goroutine receiving from the
+// client to finish before returning anyway.
+const receiveGrace = time.Second
+
+// errTimedOut ends the session of a client that stopped answering heartbeats.
+var errTimedOut = status.Error(codes.DeadlineExceeded, "missed too many heartbeats")
+
+func (cs *chatStream) send(env *gRPC.Envelope) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if err := cs.stream.Send(env); err != nil {
+		return err
+	}
+	if cs.pingWanted.CompareAndSwap(true, false) {
+		cs.stream.Send(newPing())
+	}
+	return nil
+}
+
+// isClosed reports whether the handler is done with the stream.
+func (cs *chatStream) isClosed() bool {
+	select {
+	case <-cs.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// sendChat is given to the session, so broadcast messages arrive in chat envelopes.
+func (cs *chatStream) sendChat(msg *gRPC.ChatMessage) error {
+	return cs.send(&gRPC.Envelope{Body: &gRPC.Envelope_Chat{Chat: msg}})
+}
+
+// Chat subscribes the client and takes the messages it sends on the same stream,
+// see Envelope in go.proto. Since the stream belongs to one client, the server knows
+// who sent each message without trusting the clientName in it.
+func (s *Server) Chat(stream gRPC.ChittyChat_ChatServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if hello == nil {
+		return status.Error(codes.InvalidArgument, "a chat stream has to start with a hello")
+	}
+	s.logger.Printf("User: %s is starting a chat stream", hello.ClientName)
+	sub, err := s.join(stream.Context(), hello)
+	if err != nil {
+		return err
+	}
+
+	out := &chatStream{stream: stream, closed: make(chan struct{})}
+	out.lastHeard.Store(time.Now().UnixNano())
+	// sent before the session starts, so it is the first thing the client gets.
+	// If it fails, so will everything else on the stream, which ends the session.
+	out.send(&gRPC.Envelope{Body: &gRPC.Envelope_Hello{Hello: &gRPC.SubMessage{ClientName: s.name}}})
+	sub.start(out.sendChat)
+
+	received := make(chan error, 1)
+	receiving := make(chan struct{})
+	go func() {
+		defer close(receiving)
+		received <- s.receiveChat(stream, out, hello.ClientName)
+	}()
+
+	var beats <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		beats = ticker.C
+	}
+
+	quitting := false
+wait:
+	for {
+		select {
+		case err := <-received:
+			sub.end(err)
+			break wait
+		case <-stream.Context().Done():
+			sub.end(nil)
+			break wait
+		case <-s.quit:
+			quitting = true
+			sub.drain()
+			break wait
+		case <-sub.done:
+			break wait
+		case <-beats:
+			silent := time.Since(time.Unix(0, out.lastHeard.Load()))
+			if silent >= time.Duration(s.misses)*s.heartbeat {
+				s.logger.Printf("User %s has not been heard from for %s, missing %d heartbeats", hello.ClientName, silent.Round(time.Millisecond), s.misses)
+				sub.end(errTimedOut)
+				break wait
+			}
+			out.ping()
+		}
+	}
+	// nothing the client sends from now on is published or answered
+	close(out.closed)
+	err = s.leave(sub)
+	if quitting && err == nil {
+		out.send(&gRPC.Envelope{Body: &gRPC.Envelope_Leave{Leave: &gRPC.Leave{Reason: "server is shutting down"}}})
+	}
+
+	// The stream must not be used once the handler has returned, so wait for the receiver.
+	// It stops as soon as the client sends anything or goes away, which a client that was
+	// told to leave does. A client that stopped talking can keep it waiting in Recv, or in
+	// sending an ack if it stopped reading too, and only returning ends the stream and gets
+	// it out of there. So after the grace the handler returns anyway: the call the receiver
+	// is stuck in fails then, and since out is closed, it does not touch the stream again.
+	timer := time.NewTimer(receiveGrace)
+	defer timer.Stop()
+	select {
+	case <-receiving:
+	case <-timer.C:
+		s.logger.Printf("User %s: not waiting any longer for the client to stop sending", hello.ClientName)
+	}
+	return err
+}
+
+// receiveChat handles what the client sends on a Chat stream. It returns nil when the client
+// leaves or the stream ends, and an error if the client breaks the protocol.
+func (s *Server) receiveChat(stream gRPC.ChittyChat_ChatServer, out *chatStream, name string) error {
+	for {
+		env, err := stream.Recv()
+		if err != nil {
+			// the client went away without saying goodbye
+			return nil
+		}
+		if out.isClosed() {
+			// the handler is done, and the stream with it
+			return nil
+		}
+		out.lastHeard.Store(time.Now().UnixNano())
+		switch body := env.Body.(type) {
+		case *gRPC.Envelope_Chat:
+			msg := body.Chat
+			msg.ClientName = name
+			if msg.Recipient != "" {
+				s.logger.Printf("Direct message being sent from %s to %s", name, msg.Recipient)
+			} else {
+				s.logger.Printf("Message being published")
+			}
+			accept, err := s.publish(stream.Context(), msg, msg.Recipient != "")
+			if out.isClosed() {
+				return nil
+			}
+			if err := out.send(&gRPC.Envelope{Id: env.Id, Body: &gRPC.Envelope_Ack{Ack: newAck(accept, err)}}); err != nil {
+				return nil
+			}
+
+		case *gRPC.Envelope_Ping:
+			if body.Ping.Reply {
+				continue
+			}
+			if err := out.send(&gRPC.Envelope{Id: env.Id, Body: &gRPC.Envelope_Ping{Ping: &gRPC.Ping{Reply: true}}}); err != nil {
+				return nil
+			}
+
+		case *gRPC.Envelope_Leave:
+			s.logger.Printf("User %s is leaving the chat stream: %s", name, body.Leave.Reason)
+			return nil
+
+		case *gRPC.Envelope_Hello:
+			return status.Error(codes.InvalidArgument, "hello can only be sent once")
+
+		default:
+			return status.Errorf(codes.InvalidArgument, "clients cannot send %T", env.Body)
+		}
+	}
+}
+
+// ping sends a heartbeat to the client. If something else is being sent right now, the
+// heartbeat is queued and sent right after it by send, instead of waiting here: a
+// connection that has stopped taking data would hold up the heartbeat checks. If that
+// send never finishes, neither does the heartbeat, but then the client is not reading
+// and the checks disconnect it. A heartbeat queued just as a send finishes waits for the
+// next send, or is sent at the next tick.
+func (cs *chatStream) ping() {
+	if !cs.mutex.TryLock() {
+		cs.pingWanted.Store(true)
+		return
+	}
+	defer cs.mutex.Unlock()
+	cs.pingWanted.Store(false)
+	cs.stream.Send(newPing())
+}
+
+func newPing() *gRPC.Envelope {
+	return &gRPC.Envelope{Body: &gRPC.Envelope_Ping{Ping: &gRPC.Ping{}}}
+}
+
+// newAck answers a chat envelope with the result of publishing it.
+func newAck(accept *gRPC.ChatAccept, err error) *gRPC.Ack {
+	if err != nil {
+		st := status.Convert(err).Proto()
+		return &gRPC.Ack{Code: st.Code, Error: st.Message, Details: st.Details}
+	}
+	return &gRPC.Ack{Accept: accept}
+}
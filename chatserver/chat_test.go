@@ -0,0 +1,51 @@
+package chatserver
+
+import (
+	"testing"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// slowStream is a Chat stream whose sends wait until release is closed.
+type slowStream struct {
+	gRPC.ChittyChat_ChatServer
+	release chan struct{}
+	sent    chan *gRPC.Envelope
+}
+
+func (s *slowStream) Send(env *gRPC.Envelope) error {
+	<-s.release
+	s.sent <- env
+	return nil
+}
+
+// A heartbeat due while something else is being sent is sent right after it, not skipped.
+func TestPingWhileSending(t *testing.T) {
+	stream := &slowStream{release: make(chan struct{}), sent: make(chan *gRPC.Envelope, 2)}
+	out := &chatStream{stream: stream, closed: make(chan struct{})}
+	sending := make(chan error)
+	go func() { sending <- out.sendChat(&gRPC.ChatMessage{Message: "hi"}) }()
+	for out.mutex.TryLock() {
+		// not sending yet
+		out.mutex.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	out.ping() // must not wait for the send
+	close(stream.release)
+	if err := <-sending; err != nil {
+		t.Fatal(err)
+	}
+	if env := <-stream.sent; env.GetChat() == nil {
+		t.Fatalf("sent %v first, want the chat message", env)
+	}
+	select {
+	case env := <-stream.sent:
+		if env.GetPing() == nil || env.GetPing().Reply {
+			t.Errorf("sent %v after the chat message, want a ping", env)
+		}
+	default:
+		t.Error("the ping was skipped")
+	}
+}
@@ -0,0 +1,81 @@
+package chatserver
+
+import (
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// maxMessageID is the longest message id a client can give, in bytes.
+const maxMessageID = 64
+
+// maxRemembered is the most message ids kept for deduplication at once. When there are
+// more, the oldest are forgotten before the dedup window is over.
+const maxRemembered = 10000
+
+// sentKey is a message id, which only has to be unique among the messages of one sender.
+type sentKey struct {
+	sender string
+	id     string
+}
+
+// sent is a message with an id that was accepted within the dedup window.
+type sent struct {
+	key    sentKey
+	at     time.Time
+	accept *gRPC.ChatAccept
+}
+
+// dedup remembers how the messages with an id were accepted, so a message that is sent
+// again is answered the same way instead of being broadcast twice. It is only used by the loop.
+type dedup struct {
+	window   time.Duration
+	accepted map[sentKey]*sent
+	order    []*sent // oldest first
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{window: window, accepted: make(map[sentKey]*sent)}
+}
+
+// lookup returns the ChatAccept of an earlier message from sender with the same id,
+// or nil if there was none within the window.
+func (d *dedup) lookup(sender, id string, now time.Time) *gRPC.ChatAccept {
+	d.expire(now)
+	if sent := d.accepted[sentKey{sender, id}]; sent != nil {
+		return proto.Clone(sent.accept).(*gRPC.ChatAccept)
+	}
+	return nil
+}
+
+// remember records how the message with id from sender was accepted.
+func (d *dedup) remember(sender, id string, accept *gRPC.ChatAccept, now time.Time) {
+	s := &sent{key: sentKey{sender, id}, at: now, accept: proto.Clone(accept).(*gRPC.ChatAccept)}
+	d.accepted[s.key] = s
+	d.order = append(d.order, s)
+	if len(d.order) > maxRemembered {
+		d.forget(1)
+	}
+}
+
+// expire forgets the messages accepted longer than the window before now.
+func (d *dedup) expire(now time.Time) {
+	n := 0
+	for n < len(d.order) && now.Sub(d.order[n].at) > d.window {
+		n++
+	}
+	d.forget(n)
+}
+
+// forget forgets the n oldest messages.
+func (d *dedup) forget(n int) {
+	for i, s := range d.order[:n] {
+		if d.accepted[s.key] == s {
+			delete(d.accepted, s.key)
+		}
+		d.order[i] = nil
+	}
+	d.order = d.order[n:]
+}
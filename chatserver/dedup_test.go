@@ -0,0 +1,68 @@
+package chatserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+func TestDedup(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := newDedup(time.Minute)
+	if got := d.lookup("alice", "1", start); got != nil {
+		t.Fatalf("an unknown id gave %v", got)
+	}
+	accept := &gRPC.ChatAccept{ServerName: "main", Sequence: 7}
+	d.remember("alice", "1", accept, start)
+	// the caller may change its ChatAccept afterwards
+	accept.Sequence = 8
+
+	got := d.lookup("alice", "1", start.Add(time.Minute))
+	if got == nil || got.Sequence != 7 {
+		t.Fatalf("sending again within the window gave %v, want sequence 7", got)
+	}
+	got.Sequence = 9
+	if again := d.lookup("alice", "1", start); again.Sequence != 7 {
+		t.Errorf("changing a returned ChatAccept changed the remembered one to %d", again.Sequence)
+	}
+	// ids only have to be unique per sender
+	if got := d.lookup("bob", "1", start); got != nil {
+		t.Errorf("bob's message with alice's id gave %v", got)
+	}
+	if got := d.lookup("alice", "1", start.Add(time.Minute+1)); got != nil {
+		t.Errorf("after the window the id gave %v", got)
+	}
+	if len(d.accepted) != 0 || len(d.order) != 0 {
+		t.Errorf("%d ids still remembered after the window", len(d.accepted))
+	}
+}
+
+func TestDedupRememberedAgain(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := newDedup(time.Minute)
+	d.remember("alice", "1", &gRPC.ChatAccept{Sequence: 1}, start)
+	d.remember("alice", "1", &gRPC.ChatAccept{Sequence: 2}, start.Add(30*time.Second))
+	// expiring the first one must not forget the second
+	if got := d.lookup("alice", "1", start.Add(80*time.Second)); got == nil || got.Sequence != 2 {
+		t.Errorf("got %v, want the newer sequence 2", got)
+	}
+}
+
+func TestDedupLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := newDedup(time.Hour)
+	for i := 0; i <= maxRemembered; i++ {
+		d.remember("alice", fmt.Sprint(i), &gRPC.ChatAccept{Sequence: int64(i)}, now)
+	}
+	if len(d.accepted) != maxRemembered {
+		t.Errorf("%d ids remembered, want %d", len(d.accepted), maxRemembered)
+	}
+	if got := d.lookup("alice", "0", now); got != nil {
+		t.Errorf("the oldest id was still remembered: %v", got)
+	}
+	if got := d.lookup("alice", fmt.Sprint(maxRemembered), now); got == nil {
+		t.Error("the newest id was forgotten")
+	}
+}
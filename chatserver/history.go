@@ -0,0 +1,205 @@
+package chatserver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/hannaStokes/handin3/msglog"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHistoryPage = 50   // messages in a history page if the request has no limit
+	maxHistoryPage     = 1000 // most messages in a history page
+)
+
+// DefaultHistorySize is how many of the newest messages the server keeps in memory, unless
+// WithHistorySize says otherwise.
+const DefaultHistorySize = 10000
+
+// history holds the newest broadcast messages in sequence order, for the History RPC and
+// for replaying missed messages to resumed subscriptions. Older ones are only in the
+// message log, if the server has one, which page reads them from when it has to.
+// Direct messages are kept as well, but never returned by page.
+// The loop adds to it while History handlers read it, so it has its own lock.
+// Since the loop stamps messages in order, both the sequence numbers and the Lamport
+// timestamps in it are increasing.
+type history struct {
+	mutex    sync.RWMutex
+	messages []*gRPC.ChatMessage
+	size     int         // most messages kept in messages
+	trimmed  bool        // whether older messages than those in messages were broadcast
+	log      *msglog.Log // where the older messages are, nil if they are gone
+}
+
+func (h *history) add(message *gRPC.ChatMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.messages = append(h.messages, message)
+	if len(h.messages) > h.size {
+		// append moves what is left to a new array once this one is full, so the
+		// messages cut off here do not stay in memory
+		h.messages[0] = nil
+		h.messages = h.messages[1:]
+		h.trimmed = true
+	}
+}
+
+// since returns the messages after sequence number after for which keep returns true,
+// at most limit of them, the newest ones if there are more. They are oldest first.
+// dropped is the sequence number of the newest message left out because of limit, 0 if
+// none was. Only the messages still in memory are looked at. If there were older ones
+// after after, gone is the sequence number of the oldest message in memory, which they
+// are all before, and otherwise 0. See before for reading them.
+func (h *history) since(after int64, limit int, keep func(*gRPC.ChatMessage) bool) (kept []*gRPC.ChatMessage, dropped, gone int64) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	msgs := h.messages
+	lo := sort.Search(len(msgs), func(i int) bool { return msgs[i].Sequence > after })
+	for _, msg := range msgs[lo:] {
+		if keep(msg) {
+			kept = append(kept, msg)
+		}
+	}
+	if len(kept) > limit {
+		dropped = kept[len(kept)-limit-1].Sequence
+		kept = kept[len(kept)-limit:]
+	}
+	if h.trimmed && len(msgs) > 0 && msgs[0].Sequence > after+1 {
+		gone = msgs[0].Sequence
+	}
+	return kept, dropped, gone
+}
+
+// errNoLog is returned by before when older messages than those in memory are gone.
+var errNoLog = errors.New("the server has no message log")
+
+// before reads the messages after sequence number after and before sequence number
+// until for which keep returns true from the message log, at most limit of them, the
+// newest ones if there are more. They are oldest first. dropped is the sequence number
+// of the newest message left out because of limit, 0 if none was.
+// It reads the file from the start.
+func (h *history) before(after, until int64, limit int, keep func(*gRPC.ChatMessage) bool) (kept []*gRPC.ChatMessage, dropped int64, err error) {
+	h.mutex.RLock()
+	log := h.log
+	h.mutex.RUnlock()
+	if log == nil {
+		return nil, 0, errNoLog
+	}
+	err = log.Scan(func(entry *gRPC.LogEntry) bool {
+		msg := entry.Message
+		if msg.Sequence >= until {
+			return false
+		}
+		if msg.Sequence > after && keep(msg) {
+			kept = append(kept, msg)
+			if len(kept) >= 2*limit {
+				// only the newest are kept, in a new array so the rest can go
+				dropped = kept[len(kept)-limit-1].Sequence
+				kept = append([]*gRPC.ChatMessage(nil), kept[len(kept)-limit:]...)
+			}
+		}
+		return true
+	})
+	if len(kept) > limit {
+		dropped = kept[len(kept)-limit-1].Sequence
+		kept = kept[len(kept)-limit:]
+	}
+	return kept, dropped, err
+}
+
+// page returns the messages selected by req, oldest first, and whether there are more in
+// the direction the page was read. See HistoryRequest in go.proto.
+// Pages that reach back past the messages in memory are read from the message log, which
+// means reading the file from the start.
+func (h *history) page(req *gRPC.HistoryRequest) ([]*gRPC.ChatMessage, bool, error) {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultHistoryPage
+	}
+	if limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+	forwards := (req.AfterSequence != 0 || req.AfterTimestamp != 0) && req.BeforeSequence == 0 && req.BeforeTimestamp == 0
+
+	h.mutex.RLock()
+	p := &pager{req: req, limit: limit, forwards: forwards}
+	msgs := h.messages
+	// skip what is before the after bounds
+	lo := sort.Search(len(msgs), func(i int) bool { return p.after(msgs[i]) })
+	for _, msg := range msgs[lo:] {
+		if !p.add(msg) {
+			break
+		}
+	}
+	// older messages than those in memory can only be in the page if the oldest one in memory is
+	older := h.trimmed && len(msgs) > 0 && p.after(msgs[0])
+	log := h.log
+	h.mutex.RUnlock()
+
+	// a page read backwards that found more messages in memory than fit did not need older ones
+	if !older || (!forwards && p.more) || log == nil {
+		return p.page, p.more, nil
+	}
+	p = &pager{req: req, limit: limit, forwards: forwards}
+	err := log.Scan(func(entry *gRPC.LogEntry) bool {
+		return !p.after(entry.Message) || p.add(entry.Message)
+	})
+	return p.page, p.more, err
+}
+
+// pager makes a history page out of messages given to it in sequence order.
+type pager struct {
+	req      *gRPC.HistoryRequest
+	limit    int
+	forwards bool
+	page     []*gRPC.ChatMessage
+	more     bool
+}
+
+// after reports whether msg is after the after bounds of the request.
+func (p *pager) after(msg *gRPC.ChatMessage) bool {
+	return msg.Sequence > p.req.AfterSequence && msg.Timestamp > p.req.AfterTimestamp
+}
+
+// add adds msg, which has to be after the after bounds, to the page if it belongs there.
+// It returns false once no later message can change the page.
+func (p *pager) add(msg *gRPC.ChatMessage) bool {
+	if (p.req.BeforeSequence != 0 && msg.Sequence >= p.req.BeforeSequence) ||
+		(p.req.BeforeTimestamp != 0 && msg.Timestamp >= p.req.BeforeTimestamp) {
+		return false
+	}
+	if msg.Recipient != "" || (p.req.Room != "" && msg.Room != p.req.Room && msg.Room != "") {
+		return true
+	}
+	if len(p.page) == p.limit {
+		p.more = true
+		if p.forwards {
+			return false
+		}
+		// read backwards the page is the newest messages, so the oldest one makes room
+		p.page[0] = nil
+		p.page = p.page[1:]
+	}
+	p.page = append(p.page, msg)
+	return true
+}
+
+// History returns a page of past messages, see HistoryRequest in go.proto.
+func (s *Server) History(ctx context.Context, req *gRPC.HistoryRequest) (*gRPC.HistoryPage, error) {
+	if req.BeforeSequence < 0 || req.AfterSequence < 0 || req.BeforeTimestamp < 0 || req.AfterTimestamp < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "history bounds and limit cannot be negative")
+	}
+	messages, more, err := s.history.page(req)
+	if err != nil {
+		s.logger.Printf("Server %s: Could not read the history: %v", s.name, err)
+		return nil, status.Errorf(codes.Internal, "could not read the history: %v", err)
+	}
+	s.logger.Printf("Sending %d messages of history", len(messages))
+	return &gRPC.HistoryPage{Messages: messages, More: more}, nil
+}
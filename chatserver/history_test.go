@@ -0,0 +1,201 @@
+package chatserver
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/msglog"
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// testMessages makes n messages with sequence numbers 1 to n and Lamport timestamps twice
+// that, in rooms a and b, with every seventh a direct message and every tenth to everyone.
+func testMessages(n int) []*gRPC.ChatMessage {
+	var msgs []*gRPC.ChatMessage
+	for i := 1; i <= n; i++ {
+		msg := &gRPC.ChatMessage{ClientName: "u", Message: fmt.Sprint(i), Sequence: int64(i), Timestamp: int64(2 * i), Room: "a"}
+		switch {
+		case i%7 == 0:
+			msg.Room, msg.Recipient = "", "v"
+		case i%10 == 0:
+			msg.Room = ""
+		case i%2 == 0:
+			msg.Room = "b"
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func sequences(msgs []*gRPC.ChatMessage) []int64 {
+	var seqs []int64
+	for _, msg := range msgs {
+		seqs = append(seqs, msg.Sequence)
+	}
+	return seqs
+}
+
+func TestHistoryPage(t *testing.T) {
+	h := &history{size: 100}
+	for _, msg := range testMessages(30) {
+		h.add(msg)
+	}
+	tests := []struct {
+		name string
+		req  *gRPC.HistoryRequest
+		want string
+		more bool
+	}{
+		{"newest", &gRPC.HistoryRequest{Limit: 3}, "[27 29 30]", true},
+		{"everything", &gRPC.HistoryRequest{Limit: 1000}, "[1 2 3 4 5 6 8 9 10 11 12 13 15 16 17 18 19 20 22 23 24 25 26 27 29 30]", false},
+		{"room", &gRPC.HistoryRequest{Room: "a", Limit: 4}, "[25 27 29 30]", true},
+		{"room and to everyone", &gRPC.HistoryRequest{Room: "b", BeforeSequence: 22, Limit: 4}, "[12 16 18 20]", true},
+		{"before", &gRPC.HistoryRequest{BeforeSequence: 5, Limit: 10}, "[1 2 3 4]", false},
+		{"before timestamp", &gRPC.HistoryRequest{BeforeTimestamp: 10, Limit: 2}, "[3 4]", true},
+		{"after", &gRPC.HistoryRequest{AfterSequence: 25, Limit: 10}, "[26 27 29 30]", false},
+		{"after, more", &gRPC.HistoryRequest{AfterSequence: 20, Limit: 2}, "[22 23]", true},
+		{"after timestamp", &gRPC.HistoryRequest{AfterTimestamp: 56, Limit: 10}, "[29 30]", false},
+		{"between", &gRPC.HistoryRequest{AfterSequence: 10, BeforeSequence: 16, Limit: 10}, "[11 12 13 15]", false},
+		{"between, newest first", &gRPC.HistoryRequest{AfterSequence: 10, BeforeSequence: 16, Limit: 2}, "[13 15]", true},
+		{"empty", &gRPC.HistoryRequest{AfterSequence: 20, BeforeSequence: 10}, "[]", false},
+		{"default limit", &gRPC.HistoryRequest{}, fmt.Sprint(sequences(mustPage(t, h, &gRPC.HistoryRequest{Limit: 1000}))), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, more, err := h.page(test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(sequences(page)); got != test.want || more != test.more {
+				t.Errorf("got %s more %v, want %s more %v", got, more, test.want, test.more)
+			}
+		})
+	}
+}
+
+func mustPage(t *testing.T, h *history, req *gRPC.HistoryRequest) []*gRPC.ChatMessage {
+	t.Helper()
+	page, _, err := h.page(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// A history that only keeps the newest messages has to give the same pages as one that
+// keeps them all, by reading the older ones from the message log.
+func TestHistoryPageFromLog(t *testing.T) {
+	l, err := msglog.Open(filepath.Join(t.TempDir(), "messages.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	all := &history{size: 1000}
+	window := &history{size: 20, log: l}
+	for _, msg := range testMessages(200) {
+		all.add(msg)
+		window.add(msg)
+		if err := l.Append(msg, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(window.messages) != 20 {
+		t.Fatalf("kept %d messages in memory, want 20", len(window.messages))
+	}
+
+	var reqs []*gRPC.HistoryRequest
+	for _, limit := range []int32{1, 5, 50} {
+		for _, room := range []string{"", "a", "b"} {
+			reqs = append(reqs, &gRPC.HistoryRequest{Room: room, Limit: limit})
+			for _, seq := range []int64{1, 50, 150, 175, 181, 190, 199, 200, 250} {
+				reqs = append(reqs,
+					&gRPC.HistoryRequest{Room: room, Limit: limit, BeforeSequence: seq},
+					&gRPC.HistoryRequest{Room: room, Limit: limit, AfterSequence: seq},
+					&gRPC.HistoryRequest{Room: room, Limit: limit, AfterTimestamp: 2 * seq},
+					&gRPC.HistoryRequest{Room: room, Limit: limit, AfterSequence: seq - 30, BeforeSequence: seq},
+				)
+			}
+		}
+	}
+	for _, req := range reqs {
+		want, wantMore, _ := all.page(req)
+		got, more, err := window.page(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(sequences(got)) != fmt.Sprint(sequences(want)) || more != wantMore {
+			t.Errorf("%v: got %v more %v, want %v more %v", req, sequences(got), more, sequences(want), wantMore)
+		}
+	}
+
+	// without a log, the older messages are gone
+	window.log = nil
+	if page := mustPage(t, window, &gRPC.HistoryRequest{Limit: 1000}); len(page) == 0 || page[0].Sequence <= 180 {
+		t.Errorf("without a log, got %v", sequences(page))
+	}
+}
+
+// A resumed subscription gets the messages it missed from the log once they are no longer
+// in memory, and is told when not all of them can be replayed.
+func TestMissedFromLog(t *testing.T) {
+	l, err := msglog.Open(filepath.Join(t.TempDir(), "messages.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := &Server{name: "test", logger: log.New(io.Discard, "", 0), history: history{size: 100, log: l}, rooms: make(map[string]map[*session]struct{})}
+	sub := newSession("v", 1)
+	s.addToRoom("a", sub)
+	msgs := testMessages(3000)
+	for _, msg := range msgs {
+		s.history.add(msg)
+	}
+	if err := l.AppendAll(msgs, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// what v, in room a, should get after after, all of it
+	forV := func(after int64) []int64 {
+		var seqs []int64
+		for _, msg := range msgs[after:] {
+			if msg.Recipient == "v" || msg.Recipient == "" && (msg.Room == "" || msg.Room == "a") {
+				seqs = append(seqs, msg.Sequence)
+			}
+		}
+		return seqs
+	}
+
+	tests := []struct {
+		name   string
+		after  int64
+		noLog  bool
+		notice int64 // sequence number of the notice of missing messages, 0 for none
+		want   []int64
+	}{
+		{"in memory", 2950, false, 0, forV(2950)},
+		{"from the log", 2500, false, 0, forV(2500)},
+		{"too many", 0, false, forV(0)[len(forV(0))-maxReplay-1], forV(0)[len(forV(0))-maxReplay:]},
+		{"no log", 2500, true, 2900, forV(2900)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s.history.log = l
+			if test.noLog {
+				s.history.log = nil
+			}
+			got := s.missed(sub, test.after)()
+			if test.notice != 0 {
+				if len(got) == 0 || got[0].Kind != gRPC.MessageKind_SYSTEM || got[0].Sequence != test.notice {
+					t.Fatalf("got %v first, want a notice with sequence number %d", got[:min(len(got), 1)], test.notice)
+				}
+				got = got[1:]
+			}
+			if fmt.Sprint(sequences(got)) != fmt.Sprint(test.want) {
+				t.Errorf("got %d messages %v..., want %d %v...", len(got), sequences(got[:min(len(got), 5)]), len(test.want), test.want[:min(len(test.want), 5)])
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package chatserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hannaStokes/handin3/clock"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The server state (the subscriber list, the rooms, the users, the clocks and the sequence counter) is
+// owned by a single goroutine running loop. Everything that changes it is sent to that
+// goroutine as an event and handled one at a time, so no locking is needed and every
+// event gets its own well-defined place in Lamport time.
+//
+// Because broadcast is only called from the loop, and every session queue is first in,
+// first out, all subscribers see broadcast messages in the same total order: the order of
+// their sequence numbers. A subscriber can miss messages if its queue overflows, and only
+// gets the messages of the rooms it is in, but it never sees them reordered.
+
+// joinEvent adds a subscriber to room and tells the room about it, unless it resumes a
+// subscription that ended within the resume grace window. done is closed once it is added.
+type joinEvent struct {
+	sub          *session
+	room         string
+	timestamp    int64      // Lamport time of the client when it subscribed
+	clock        clock.Time // time of the client's extra clock when it subscribed, if it has one
+	lastSequence int64      // last message the client got before it reconnected, 0 if it is new
+	rooms        []string   // the other rooms the client was in before it reconnected
+	done         chan struct{}
+}
+
+// leaveEvent removes a subscriber and tells the rooms it was in about it, after the resume
+// grace window if there is one.
+type leaveEvent struct {
+	sub *session
+}
+
+// departEvent is sent when the grace window of a departure is over, see resume.go.
+type departEvent struct {
+	departure *departure
+}
+
+// publishEvent broadcasts a chat message in its room, or sends a direct message to its
+// recipient. The server's times after the broadcast, or why the message was rejected, are sent on accepted.
+type publishEvent struct {
+	message  *gRPC.ChatMessage
+	clock    clock.Time // decoded from message.Clock
+	address  string     // the host the message came from, for rate limiting
+	accepted chan publishResult
+}
+
+// registerEvent asks whether a client at address can register an account now. The
+// answer, nil or why not, is sent on allowed.
+type registerEvent struct {
+	address string
+	allowed chan error
+}
+
+type publishResult struct {
+	accept *gRPC.ChatAccept
+	err    error
+}
+
+// joinRoomEvent adds every subscription of the client called name to room.
+// leaveRoomEvent takes them out again. The result is sent on done.
+type joinRoomEvent struct {
+	name      string
+	room      string
+	timestamp int64
+	done      chan roomResult
+}
+
+type leaveRoomEvent struct {
+	name      string
+	room      string
+	timestamp int64
+	done      chan roomResult
+}
+
+type roomResult struct {
+	timestamp int64 // Lamport time of the server after the change
+	err       error
+}
+
+// listRoomsEvent asks the loop for the rooms and who is in them.
+type listRoomsEvent struct {
+	rooms chan []*gRPC.RoomInfo
+}
+
+// listUsersEvent asks the loop for the connected users.
+type listUsersEvent struct {
+	users chan []*gRPC.UserInfo
+}
+
+// setStatusEvent changes the status of the user called name. The result is sent on done.
+type setStatusEvent struct {
+	name      string
+	status    gRPC.UserStatus
+	timestamp int64
+	done      chan statusResult
+}
+
+type statusResult struct {
+	info *gRPC.UserInfo
+	err  error
+}
+
+// watchEvent starts a presence watcher, which is sent on ready. unwatchEvent stops it again.
+type watchEvent struct {
+	ready chan *watcher
+}
+
+type unwatchEvent struct {
+	watcher *watcher
+}
+
+// tickEvent moves the clock past timestamp. The new time is sent on now.
+type tickEvent struct {
+	timestamp int64
+	now       chan int64
+}
+
+// shutdownEvent broadcasts the farewell message. done is closed once it is queued for everyone.
+type shutdownEvent struct {
+	done chan struct{}
+}
+
+// statsEvent asks the loop for a copy of the server statistics.
+type statsEvent struct {
+	stats chan serverStats
+}
+
+// serverStats are counted by the loop while the server runs and logged when it shuts down.
+type serverStats struct {
+	started         time.Time
+	joins           int   // subscriptions over the lifetime of the server
+	peakSubscribers int   // most subscribers at the same time
+	broadcasts      int   // messages broadcast, including join, leave and system messages
+	dropped         int64 // messages dropped for sessions that have left
+	rateLimited     int   // messages refused because their sender was sending too fast
+	lamport         int64 // Lamport time when the statistics were read
+}
+
+// submit hands an event to the loop. It returns false if the loop has stopped.
+func (s *Server) submit(e any) bool {
+	select {
+	case s.events <- e:
+		return true
+	case <-s.stopped:
+		return false
+	}
+}
+
+// loop handles events until the server has stopped.
+func (s *Server) loop() {
+	for {
+		select {
+		case e := <-s.events:
+			s.handle(e)
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+func (s *Server) handle(e any) {
+	switch e := e.(type) {
+	case joinEvent:
+		name := e.sub.name
+		s.increaseLamport(e.timestamp)
+		s.observeClock(name, e.clock, "")
+		s.subscribers = append(s.subscribers, e.sub)
+		s.addToRoom(e.room, e.sub)
+		s.stats.joins++
+		if len(s.subscribers) > s.stats.peakSubscribers {
+			s.stats.peakSubscribers = len(s.subscribers)
+		}
+		s.logger.Printf("Added subscriber to list and to room %s.\n           Number of subscribed clients: %d \n", e.room, len(s.subscribers))
+		var resumed *departure
+		if e.lastSequence > 0 {
+			resumed = s.resume(name)
+		}
+		if resumed != nil {
+			// back within the grace window, nobody was told it left
+			for _, room := range resumed.rooms {
+				s.addToRoom(room, e.sub)
+			}
+			s.logger.Printf("User %s resumed its subscription after message #%d, back in rooms %v", name, e.lastSequence, s.roomsOf(e.sub))
+		}
+		var rejoined []string // the rooms to tell the client is back in
+		if resumed == nil && e.lastSequence > 0 {
+			// too late to resume, but it gets what it missed in all its rooms, not just in e.room
+			for _, room := range e.rooms {
+				if !s.inRoom(name, room) {
+					rejoined = append(rejoined, room)
+				}
+				s.addToRoom(room, e.sub)
+			}
+		}
+		if e.lastSequence > 0 {
+			// before the join message below, which the session gets in its queue
+			e.sub.backlog = s.missed(e.sub, e.lastSequence)
+		}
+		if resumed == nil {
+			msg := fmt.Sprintf("User %s subscribed", name)
+			joinMsg := &gRPC.ChatMessage{ClientName: name, Message: msg, Kind: gRPC.MessageKind_JOIN, Room: e.room}
+			s.broadcast(joinMsg)
+			s.userJoined(name, joinMsg.Timestamp)
+			for _, room := range rejoined {
+				msg := fmt.Sprintf("User %s joined room %s", name, room)
+				s.broadcast(&gRPC.ChatMessage{ClientName: name, Message: msg, Kind: gRPC.MessageKind_JOIN, Room: room})
+			}
+		}
+		close(e.done)
+
+	case leaveEvent:
+		name := e.sub.name
+		//remove subscriber from s.subscribers and its rooms, and send out "user logged off" message to the remaining ones
+		for i, c := range s.subscribers {
+			if c == e.sub {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				break
+			}
+		}
+		rooms := s.roomsOf(e.sub)
+		for _, room := range rooms {
+			s.removeFromRoom(room, e.sub)
+		}
+		s.stats.dropped += e.sub.dropped.Load()
+		s.logger.Printf("Removed subscriber from list, %d messages were dropped for it.\n           Number of subscribed clients: %d \n", e.sub.dropped.Load(), len(s.subscribers))
+		if s.closing {
+			// everyone is leaving, the farewell message already told them why
+			s.userLeft(name)
+			return
+		}
+		lvmsg := fmt.Sprintf("User %s left the server", name)
+		if e.sub.err == errTimedOut {
+			lvmsg = fmt.Sprintf("User %s timed out", name)
+		}
+		if s.resumeGrace > 0 {
+			s.depart(name, rooms, lvmsg)
+			return
+		}
+		s.announceLeave(name, rooms, lvmsg)
+
+	case departEvent:
+		s.departed(e.departure)
+
+	case persistedEvent:
+		s.deliver(e.batch)
+
+	case publishEvent:
+		if e.message.MessageId != "" && s.dedup != nil {
+			if accept := s.dedup.lookup(e.message.ClientName, e.message.MessageId, time.Now()); accept != nil {
+				s.logger.Printf("Message %s from %s was already published as #%d, not broadcasting it again", e.message.MessageId, e.message.ClientName, accept.Sequence)
+				e.accepted <- publishResult{accept: accept}
+				return
+			}
+		}
+		if err := s.checkRate(e.message.ClientName, e.address, time.Now()); err != nil {
+			e.accepted <- publishResult{err: err}
+			return
+		}
+		if e.message.Recipient != "" && len(s.sessionsOf(e.message.ClientName)) == 0 {
+			// a room message is checked below, with its room
+			e.accepted <- publishResult{err: status.Errorf(codes.FailedPrecondition, "user %s is not subscribed", e.message.ClientName)}
+			return
+		}
+		if e.message.Recipient != "" && len(s.sessionsOf(e.message.Recipient)) == 0 && len(s.departures[e.message.Recipient]) == 0 {
+			e.accepted <- publishResult{err: status.Errorf(codes.NotFound, "user %s is not connected", e.message.Recipient)}
+			return
+		}
+		if e.message.Recipient == "" && !s.inRoom(e.message.ClientName, e.message.Room) {
+			e.accepted <- publishResult{err: status.Errorf(codes.FailedPrecondition, "user %s is not in room %s", e.message.ClientName, e.message.Room)}
+			return
+		}
+		s.increaseLamport(e.message.Timestamp)
+		entry := clock.RoomEntry(e.message.ClientName, e.message.Room)
+		if e.message.Recipient != "" {
+			entry = clock.DirectEntry(e.message.ClientName, e.message.Recipient)
+		}
+		s.observeClock(e.message.ClientName, e.clock, entry)
+		s.broadcast(e.message)
+		accept := &gRPC.ChatAccept{ServerName: s.name, Timestamp: s.lamport.Now().Counter, Sequence: e.message.Sequence}
+		if s.logical != nil {
+			accept.Clock = s.logical.Encode()
+		}
+		if e.message.MessageId != "" && s.dedup != nil {
+			s.dedup.remember(e.message.ClientName, e.message.MessageId, accept, time.Now())
+		}
+		// the sender hears back once the message is on its way, and on disk
+		s.afterBroadcast(func() { e.accepted <- publishResult{accept: accept} })
+
+	case registerEvent:
+		e.allowed <- s.checkAddressRate(e.address, time.Now())
+
+	case joinRoomEvent:
+		s.increaseLamport(e.timestamp)
+		err := s.joinRoom(e.name, e.room)
+		e.done <- roomResult{timestamp: s.lamport.Now().Counter, err: err}
+
+	case leaveRoomEvent:
+		s.increaseLamport(e.timestamp)
+		err := s.leaveRoom(e.name, e.room)
+		e.done <- roomResult{timestamp: s.lamport.Now().Counter, err: err}
+
+	case listRoomsEvent:
+		e.rooms <- s.listRooms()
+
+	case listUsersEvent:
+		e.users <- s.listUsers()
+
+	case setStatusEvent:
+		s.increaseLamport(e.timestamp)
+		info, err := s.setStatus(e.name, e.status)
+		e.done <- statusResult{info: info, err: err}
+
+	case watchEvent:
+		e.ready <- s.watch()
+
+	case unwatchEvent:
+		delete(s.watchers, e.watcher)
+
+	case tickEvent:
+		s.increaseLamport(e.timestamp)
+		e.now <- s.lamport.Now().Counter
+
+	case shutdownEvent:
+		s.closing = true
+		msg := fmt.Sprintf("Server %s is shutting down", s.name)
+		s.broadcast(&gRPC.ChatMessage{ClientName: s.name, Message: msg, Kind: gRPC.MessageKind_SYSTEM})
+		s.afterBroadcast(func() { close(e.done) })
+
+	case statsEvent:
+		stats := s.stats
+		stats.lamport = s.lamport.Now().Counter
+		for _, sub := range s.subscribers {
+			stats.dropped += sub.dropped.Load()
+		}
+		e.stats <- stats
+	}
+}
+
+// increaseLamport moves the server clock past timestamp. Only called from the loop.
+func (s *Server) increaseLamport(timestamp int64) {
+	s.logger.Printf("Comparing Lamport times and adding 1 to max.\n           Server: %d, Client: %d \n", s.lamport.Now().Counter, timestamp)
+	s.lamport.Observe(clock.LamportTime(timestamp))
+}
+
+// observeClock moves the server's extra clock past a time received from the client called
+// name, if both have one. Only called from the loop.
+//
+// Of a vector, only the client's own entry and entry, the one the message it came with is
+// counted on, are taken. Everything else a client knows it learned from the server, which
+// already has it, so other entries could only be made up, and would be merged into the
+// server's clock for good and sent with everything after.
+func (s *Server) observeClock(name string, t clock.Time, entry string) {
+	if s.logical == nil || t.IsZero() {
+		return
+	}
+	if t.Kind == clock.KindVector {
+		own := clock.Vector{}
+		for _, id := range []string{name, entry} {
+			if n, ok := t.Vector[id]; ok && id != "" {
+				own[id] = n
+			}
+		}
+		t.Vector = own
+	}
+	s.logical.Observe(t)
+}
+
+// broadcast stamps message with the next sequence number and the Lamport time of the
+// send event, adds it to the history, and queues it for every subscriber in its room, or
+// every subscriber at all if it has no room. Direct messages are only queued for the
+// subscriptions of their recipient. If the server has a message log, it is only queued
+// once it is written there, see persist.go.
+// Only called from the loop.
+// The message is shared by all sessions, so it must not be changed afterwards.
+func (s *Server) broadcast(message *gRPC.ChatMessage) {
+	s.sequence++
+	s.logger.Printf("Broadcasting message #%d \"%s\" to all users.\n           Increasing Lamport Time %d by 1. \n \n", s.sequence, message.Message, s.lamport.Now().Counter)
+	message.Timestamp = s.lamport.Tick().Counter //receive and send are separate events
+	message.Sequence = s.sequence
+	if s.logical != nil {
+		if message.Kind != gRPC.MessageKind_CHAT {
+			// the server is the sender of everything that is not a chat message
+			message.Clock = s.logical.Tick().Encode()
+		}
+		sent, _ := clock.Decode(message.Clock)
+		s.logger.Printf("Message #%d was sent at %s time %s, server %s time is %s", message.Sequence, s.logical.Kind(), sent, s.logical.Kind(), s.logical.Now())
+	}
+	if message.Account != "" {
+		s.logger.Printf("Message #%d was sent by the account %s", message.Sequence, message.Account)
+	}
+	s.stats.broadcasts++
+	s.history.add(message)
+	sessions := s.recipients(message)
+	if s.persister != nil {
+		s.persister.add(unsent{message: message, sessions: sessions})
+		return
+	}
+	for _, sub := range sessions {
+		s.enqueue(sub, message)
+	}
+}
+
+// recipients returns the subscriptions message is for. Only called from the loop.
+func (s *Server) recipients(message *gRPC.ChatMessage) []*session {
+	if message.Recipient != "" {
+		return s.sessionsOf(message.Recipient)
+	}
+	if message.Room == "" {
+		return append([]*session(nil), s.subscribers...)
+	}
+	sessions := make([]*session, 0, len(s.rooms[message.Room]))
+	for sub := range s.rooms[message.Room] {
+		sessions = append(sessions, sub)
+	}
+	return sessions
+}
+
+// logStats logs the statistics of a server that is shutting down.
+func (s *Server) logStats(stats serverStats) {
+	s.logger.Printf("Server %s: Stopped after %s.\n           Subscriptions: %d, most at once: %d, messages broadcast: %d, messages dropped: %d, messages rate limited: %d, final Lamport time: %d \n",
+		s.name, time.Since(stats.started).Round(time.Second), stats.joins, stats.peakSubscribers, stats.broadcasts, stats.dropped, stats.rateLimited, stats.lamport)
+}
@@ -0,0 +1,84 @@
+package chatserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Passwords are stored as PBKDF2-HMAC-SHA256 hashes with a random salt, in the form
+//
+//	pbkdf2-sha256$<iterations>$<salt>$<hash>
+//
+// with salt and hash in unpadded base64. The iterations are stored with every hash, so
+// hashIterations can be raised later without breaking the accounts made before.
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 600000 // what OWASP recommends for PBKDF2-HMAC-SHA256, about a tenth of a second
+	saltSize       = 16
+	hashSize       = sha256.Size
+)
+
+// hashPassword returns the stored form of password, with a new salt.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := pbkdf2([]byte(password), salt, hashIterations, hashSize)
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// checkPassword reports whether password is the one stored as encoded by hashPassword.
+func checkPassword(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false, fmt.Errorf("not a %s password hash", hashScheme)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false, fmt.Errorf("bad iteration count %q in password hash", parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, fmt.Errorf("bad salt in password hash: %w", err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, fmt.Errorf("bad hash in password hash")
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(want))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// pbkdf2 derives a key of keyLen bytes from password and salt with HMAC-SHA256, as in RFC 8018.
+// (crypto/pbkdf2 only came with Go 1.24.)
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+hashSize)
+	u := make([]byte, hashSize)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,121 @@
+package chatserver
+
+import (
+	"sync"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// When the server has a message log, broadcast messages are only queued for their
+// subscribers once they are on disk. Otherwise a crash could lose messages clients already
+// have, and the restarted server would hand their sequence numbers out again.
+//
+// Waiting for the disk in the loop would hold up every other event, so broadcast hands the
+// messages to the persister, which writes them on its own goroutine and sends them back to
+// the loop as a persistedEvent when they are on disk. Whatever is broadcast while one batch
+// is being written goes into the next one, which is written with a single sync (group commit),
+// so a busy server does not need a sync per message. Batches are written and sent back in
+// the order they were broadcast, and the loop delivers them in that order, so subscribers
+// still see every message in sequence order.
+
+// unsent is a broadcast message waiting to be persisted, with the subscribers it is for.
+// The subscribers are chosen when it is broadcast, so it goes to those that were in its room
+// then, as it does without a log. then, if set, is called by the loop after the message is
+// queued for them. An unsent without a message only calls then, after everything before it.
+type unsent struct {
+	message  *gRPC.ChatMessage
+	sessions []*session
+	then     func()
+}
+
+// persistedEvent is sent by the persister with messages that are on disk now.
+type persistedEvent struct {
+	batch []unsent
+}
+
+// persister queues what the loop broadcasts for the goroutine running persist. Adding to it
+// never blocks, so the loop and the persister cannot end up waiting for each other.
+type persister struct {
+	mutex  sync.Mutex
+	queued []unsent
+	// ready has a value in it while there is something to take
+	ready chan struct{}
+}
+
+func newPersister() *persister {
+	return &persister{ready: make(chan struct{}, 1)}
+}
+
+func (p *persister) add(u unsent) {
+	p.mutex.Lock()
+	p.queued = append(p.queued, u)
+	p.mutex.Unlock()
+	select {
+	case p.ready <- struct{}{}:
+	default:
+		// already signalled, and the next take gets this too
+	}
+}
+
+// take returns everything queued, oldest first.
+func (p *persister) take() []unsent {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	queued := p.queued
+	p.queued = nil
+	return queued
+}
+
+// persist writes what is queued in the persister to the message log, one batch at a time,
+// and hands every batch back to the loop, until the server has stopped.
+func (s *Server) persist() {
+	for {
+		select {
+		case <-s.persister.ready:
+		case <-s.stopped:
+			return
+		}
+		batch := s.persister.take()
+		var messages []*gRPC.ChatMessage
+		for _, u := range batch {
+			if u.message != nil {
+				messages = append(messages, u.message)
+			}
+		}
+		if len(messages) > 0 {
+			if err := s.messageLog.AppendAll(messages, time.Now()); err != nil {
+				// sent anyway, they are in the history and it is too late to refuse them
+				s.logger.Printf("Server %s: Failed to persist messages #%d to #%d: %v", s.name, messages[0].Sequence, messages[len(messages)-1].Sequence, err)
+			}
+		}
+		if !s.submit(persistedEvent{batch: batch}) {
+			return
+		}
+	}
+}
+
+// deliver queues broadcast messages for the subscribers they were broadcast to.
+// Only called from the loop.
+func (s *Server) deliver(batch []unsent) {
+	for _, u := range batch {
+		if u.message != nil {
+			for _, sub := range u.sessions {
+				s.enqueue(sub, u.message)
+			}
+		}
+		if u.then != nil {
+			u.then()
+		}
+	}
+}
+
+// afterBroadcast calls f once everything broadcast so far has been queued for its
+// subscribers, which is right away if the server has no message log. Only called from the loop.
+func (s *Server) afterBroadcast(f func()) {
+	if s.persister == nil {
+		f()
+		return
+	}
+	s.persister.add(unsent{then: f})
+}
@@ -0,0 +1,76 @@
+package chatserver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// With a message log, clients sending at the same time only get messages that are on disk
+// already, and the log has every message once, in sequence order.
+func TestMessagesAreSavedBeforeTheyAreSent(t *testing.T) {
+	clients, messages := 10, 20
+	l := openLog(t, filepath.Join(t.TempDir(), "messages.log"))
+	addr := startServer(t, chatserver.WithMessageLog(l), chatserver.WithQueueSize(clients*messages*2))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	quiet := log.New(io.Discard, "", 0)
+
+	all := make([]*chatclient.Client, clients)
+	for i := range all {
+		c, err := chatclient.Dial(ctx, addr, chatclient.WithName(fmt.Sprint("c", i)), chatclient.WithLogger(quiet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		all[i] = c
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range all {
+		wg.Add(1)
+		go func(i int, c *chatclient.Client) {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				if err := c.Send(ctx, fmt.Sprintf("message %d of c%d", j, i)); err != nil {
+					t.Errorf("client %d: %v", i, err)
+					return
+				}
+			}
+		}(i, c)
+	}
+	// every broadcast message is logged, so #n is only sent once there are n entries
+	got := 0
+	for got < clients*messages {
+		select {
+		case msg := <-all[0].Messages():
+			if int64(l.Len()) < msg.Sequence {
+				t.Fatalf("got #%d when the log only had %d messages", msg.Sequence, l.Len())
+			}
+			if msg.Kind == chatclient.KindChat {
+				got++
+			}
+		case <-ctx.Done():
+			t.Fatalf("got only %d messages", got)
+		}
+	}
+	wg.Wait()
+
+	var want int64
+	l.Scan(func(entry *gRPC.LogEntry) bool {
+		want++
+		if entry.Message.Sequence != want {
+			t.Fatalf("entry %d of the log is #%d", want, entry.Message.Sequence)
+		}
+		return true
+	})
+}
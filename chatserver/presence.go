@@ -0,0 +1,182 @@
+package chatserver
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// user is the presence of one connected client name, kept by the loop in s.users.
+// A user can be subscribed more than once; it stays online until the last subscription ends.
+type user struct {
+	connected   time.Time // when the oldest open subscription started
+	joined      int64     // Lamport timestamp of the join message of that subscription
+	status      gRPC.UserStatus
+	connections int
+}
+
+func (u *user) info(name string) *gRPC.UserInfo {
+	return &gRPC.UserInfo{
+		ClientName:        name,
+		ConnectedUnixNano: u.connected.UnixNano(),
+		JoinTimestamp:     u.joined,
+		Status:            u.status,
+		Connections:       int32(u.connections),
+	}
+}
+
+// watcher is one WatchPresence stream. The loop puts updates in its channel, and if the
+// stream cannot keep up, closes overflow and forgets about it.
+type watcher struct {
+	updates  chan *gRPC.PresenceUpdate
+	overflow chan struct{}
+}
+
+// ListUsers returns every connected user.
+func (s *Server) ListUsers(ctx context.Context, req *gRPC.ListUsersRequest) (*gRPC.UserList, error) {
+	users := make(chan []*gRPC.UserInfo, 1)
+	if !s.submit(listUsersEvent{users: users}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return &gRPC.UserList{Users: <-users}, nil
+}
+
+// SetStatus changes the status of a connected user and tells the presence watchers about it.
+func (s *Server) SetStatus(ctx context.Context, req *gRPC.StatusRequest) (*gRPC.UserInfo, error) {
+	if _, ok := gRPC.UserStatus_name[int32(req.Status)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown status %d", req.Status)
+	}
+	if err := s.checkCaller(ctx, req.ClientName); err != nil {
+		return nil, err
+	}
+	s.logger.Printf("User %s is setting its status to %s", req.ClientName, req.Status)
+	done := make(chan statusResult, 1)
+	if !s.submit(setStatusEvent{name: req.ClientName, status: req.Status, timestamp: req.Timestamp, done: done}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	res := <-done
+	return res.info, res.err
+}
+
+// WatchPresence streams the presence of every user, see go.proto.
+func (s *Server) WatchPresence(req *gRPC.PresenceRequest, stream gRPC.ChittyChat_WatchPresenceServer) error {
+	select {
+	case <-s.quit:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	default:
+	}
+	ready := make(chan *watcher, 1)
+	if !s.submit(watchEvent{ready: ready}) {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	w := <-ready
+	defer s.submit(unwatchEvent{watcher: w})
+
+	for {
+		select {
+		case update := <-w.updates:
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		case <-w.overflow:
+			return status.Error(codes.ResourceExhausted, "too slow to keep up with presence updates")
+		case <-stream.Context().Done():
+			return nil
+		case <-s.quit:
+			return nil
+		}
+	}
+}
+
+// The functions below keep s.users and s.watchers. Like everything else in the server
+// state, they are only called from the loop.
+
+// userJoined counts a new subscription of the client called name, whose join message was
+// stamped with the Lamport time joined.
+func (s *Server) userJoined(name string, joined int64) {
+	u := s.users[name]
+	if u != nil {
+		u.connections++
+		return
+	}
+	u = &user{connected: time.Now(), joined: joined, connections: 1}
+	s.users[name] = u
+	s.notifyWatchers(gRPC.PresenceUpdate_ONLINE, u.info(name))
+}
+
+// userLeft counts the end of a subscription of the client called name.
+func (s *Server) userLeft(name string) {
+	u := s.users[name]
+	if u == nil {
+		return
+	}
+	u.connections--
+	if u.connections > 0 {
+		return
+	}
+	delete(s.users, name)
+	s.notifyWatchers(gRPC.PresenceUpdate_OFFLINE, u.info(name))
+}
+
+// setStatus handles a setStatusEvent.
+func (s *Server) setStatus(name string, st gRPC.UserStatus) (*gRPC.UserInfo, error) {
+	u := s.users[name]
+	if u == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "user %s is not subscribed", name)
+	}
+	info := u.info(name)
+	if u.status == st {
+		return info, nil
+	}
+	u.status = st
+	info.Status = st
+	s.notifyWatchers(gRPC.PresenceUpdate_STATUS, info)
+	return info, nil
+}
+
+// listUsers handles a listUsersEvent.
+func (s *Server) listUsers() []*gRPC.UserInfo {
+	names := make([]string, 0, len(s.users))
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	users := make([]*gRPC.UserInfo, len(names))
+	for i, name := range names {
+		users[i] = s.users[name].info(name)
+	}
+	return users
+}
+
+// watch makes a watcher that starts with an ONLINE update for every connected user.
+func (s *Server) watch() *watcher {
+	w := &watcher{
+		updates:  make(chan *gRPC.PresenceUpdate, len(s.users)+s.queueSize),
+		overflow: make(chan struct{}),
+	}
+	now := s.lamport.Now().Counter
+	for _, info := range s.listUsers() {
+		w.updates <- &gRPC.PresenceUpdate{Change: gRPC.PresenceUpdate_ONLINE, User: info, Timestamp: now}
+	}
+	s.watchers[w] = struct{}{}
+	return w
+}
+
+// notifyWatchers queues an update for every watcher. A watcher whose queue is full is dropped.
+func (s *Server) notifyWatchers(change gRPC.PresenceUpdate_Change, info *gRPC.UserInfo) {
+	s.logger.Printf("Presence of %s: %s, status %s", info.ClientName, change, info.Status)
+	update := &gRPC.PresenceUpdate{Change: change, User: info, Timestamp: s.lamport.Now().Counter}
+	for w := range s.watchers {
+		select {
+		case w.updates <- update:
+		default:
+			close(w.overflow)
+			delete(s.watchers, w)
+		}
+	}
+}
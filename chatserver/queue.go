@@ -0,0 +1,102 @@
+package chatserver
+
+import (
+	"fmt"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SlowConsumerPolicy decides what happens to a broadcast message when a subscriber's
+// outbound queue is full because the client does not read fast enough.
+type SlowConsumerPolicy int
+
+const (
+	// BlockWithTimeout waits up to the block timeout for room in the queue, then drops the message.
+	// Broadcasting happens in the server loop, so while it waits the whole server waits:
+	// no other message is sent, and nobody can join or leave.
+	BlockWithTimeout SlowConsumerPolicy = iota
+	// DropOldest throws away the oldest queued message to make room for the new one.
+	DropOldest
+	// DropNewest throws away the new message and keeps the queue as it is.
+	DropNewest
+	// Disconnect ends the slow client's subscription. This is the default: a client that
+	// reconnects within the resume grace window gets the messages it missed replayed, so
+	// nothing is lost unless it stays behind for good.
+	Disconnect
+)
+
+var policyNames = map[SlowConsumerPolicy]string{
+	BlockWithTimeout: "block",
+	DropOldest:       "drop-oldest",
+	DropNewest:       "drop-newest",
+	Disconnect:       "disconnect",
+}
+
+func (p SlowConsumerPolicy) String() string {
+	if name, ok := policyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("SlowConsumerPolicy(%d)", int(p))
+}
+
+// ParseSlowConsumerPolicy turns "block", "drop-oldest", "drop-newest" or "disconnect"
+// into a SlowConsumerPolicy, so the policy can be given as a flag.
+func ParseSlowConsumerPolicy(name string) (SlowConsumerPolicy, error) {
+	for p, n := range policyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown slow consumer policy %q", name)
+}
+
+// enqueue puts message in the session's queue, following the server's slow consumer
+// policy if the queue is full. Sessions that have already ended are skipped.
+func (s *Server) enqueue(sub *session, message *gRPC.ChatMessage) {
+	select {
+	case <-sub.done:
+		return
+	case sub.queue <- message:
+		return
+	default:
+	}
+
+	switch s.slowPolicy {
+	case BlockWithTimeout:
+		timer := time.NewTimer(s.blockTimeout)
+		defer timer.Stop()
+		select {
+		case sub.queue <- message:
+			return
+		case <-sub.done:
+			return
+		case <-timer.C:
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.queue <- message:
+				return
+			default:
+			}
+			select {
+			case <-sub.queue:
+				s.dropped(sub)
+			default:
+			}
+		}
+	case Disconnect:
+		s.logger.Printf("User %s is too slow to keep up and is being disconnected", sub.name)
+		sub.end(status.Errorf(codes.ResourceExhausted, "user %s could not keep up with the chat", sub.name))
+	}
+	s.dropped(sub)
+}
+
+func (s *Server) dropped(sub *session) {
+	total := sub.dropped.Add(1)
+	s.logger.Printf("Queue of user %s is full, dropped a message (%s).\n           Messages dropped for %s so far: %d \n", sub.name, s.slowPolicy, sub.name, total)
+}
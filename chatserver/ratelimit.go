@@ -0,0 +1,222 @@
+package chatserver
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimit limits how many messages can be published with a token bucket: it holds up to
+// Burst messages, every message takes one, and it fills up again with Rate messages per second.
+// A Burst below 1 counts as 1, as a bucket that cannot hold a whole message would refuse everything.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Mute makes the server stop taking messages from a client for Duration when it has been
+// rate limited Strikes times within Within, and tells everyone about it.
+type Mute struct {
+	Strikes  int
+	Within   time.Duration
+	Duration time.Duration
+}
+
+// maxBuckets is how many token buckets a limiter keeps before it forgets the full ones,
+// which belong to clients that have not sent anything for a while. A muter sweeps its
+// strikes at the same size.
+const maxBuckets = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time // when tokens was last filled up
+}
+
+// limiter keeps a token bucket per key, a client name or an address. Like everything
+// else in the server state, it is only used by the loop.
+type limiter struct {
+	limit   RateLimit
+	buckets map[string]*bucket
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	limit.Burst = max(limit.Burst, 1)
+	return &limiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// fill returns the bucket of key with the tokens it got since it was last filled up.
+func (l *limiter) fill(key string, now time.Time) *bucket {
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxBuckets {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.limit.Rate
+	if b.tokens > float64(l.limit.Burst) {
+		b.tokens = float64(l.limit.Burst)
+	}
+	b.last = now
+	return b
+}
+
+// wait returns how long until key can send a message, 0 if it can now.
+func (l *limiter) wait(key string, now time.Time) time.Duration {
+	b := l.fill(key, now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+}
+
+// take counts a message sent by key.
+func (l *limiter) take(key string, now time.Time) {
+	l.fill(key, now).tokens--
+}
+
+// sweep forgets the buckets that are full again, they are the same as new ones.
+func (l *limiter) sweep(now time.Time) {
+	for key := range l.buckets {
+		if l.fill(key, now).tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// muter remembers when clients were rate limited and which of them are muted.
+// It is only used by the loop.
+type muter struct {
+	Mute
+	strikes map[string][]time.Time // by client name, oldest first
+	until   map[string]time.Time   // when the mute of a client name ends
+}
+
+func newMuter(mute Mute) *muter {
+	return &muter{Mute: mute, strikes: make(map[string][]time.Time), until: make(map[string]time.Time)}
+}
+
+// muted returns how long name is still muted for, 0 if it is not.
+func (m *muter) muted(name string, now time.Time) time.Duration {
+	until, ok := m.until[name]
+	if !ok {
+		return 0
+	}
+	if !now.Before(until) {
+		delete(m.until, name)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// strike counts that name was rate limited, and reports whether that got it muted.
+func (m *muter) strike(name string, now time.Time) bool {
+	recent, ok := m.strikes[name]
+	if !ok && len(m.strikes) >= maxBuckets {
+		m.sweep(now)
+	}
+	for len(recent) > 0 && now.Sub(recent[0]) > m.Within {
+		recent = recent[1:]
+	}
+	recent = append(recent, now)
+	if len(recent) < m.Strikes {
+		m.strikes[name] = recent
+		return false
+	}
+	delete(m.strikes, name)
+	m.until[name] = now.Add(m.Duration)
+	return true
+}
+
+// sweep forgets the strikes that are too old to count and the mutes that are over,
+// as if those clients had never been rate limited.
+func (m *muter) sweep(now time.Time) {
+	for name, recent := range m.strikes {
+		if now.Sub(recent[len(recent)-1]) > m.Within {
+			delete(m.strikes, name)
+		}
+	}
+	for name, until := range m.until {
+		if !now.Before(until) {
+			delete(m.until, name)
+		}
+	}
+}
+
+// peerAddress returns the host a request came from, so clients connecting from the same
+// machine share the rate limit of its address.
+func peerAddress(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// checkRate decides whether the client called name at address can publish a message now,
+// and counts it if it can. Clients that go over the limit too often are muted.
+func (s *Server) checkRate(name, address string, now time.Time) error {
+	if s.muter != nil {
+		if left := s.muter.muted(name, now); left > 0 {
+			return retryLater(codes.PermissionDenied, left, fmt.Sprintf("user %s is muted for sending too many messages", name))
+		}
+	}
+	var wait time.Duration
+	if s.clientLimit != nil {
+		wait = s.clientLimit.wait(name, now)
+	}
+	if s.addressLimit != nil && address != "" {
+		wait = max(wait, s.addressLimit.wait(address, now))
+	}
+	if wait == 0 {
+		if s.clientLimit != nil {
+			s.clientLimit.take(name, now)
+		}
+		if s.addressLimit != nil && address != "" {
+			s.addressLimit.take(address, now)
+		}
+		return nil
+	}
+
+	s.stats.rateLimited++
+	s.logger.Printf("User %s at %s is sending too fast, it can send again in %s", name, address, wait.Round(time.Millisecond))
+	if s.muter != nil && s.muter.strike(name, now) {
+		s.logger.Printf("Muting user %s for %s", name, s.muter.Duration)
+		msg := fmt.Sprintf("User %s is muted for %s for sending too many messages", name, s.muter.Duration)
+		s.broadcast(&gRPC.ChatMessage{ClientName: name, Message: msg, Kind: gRPC.MessageKind_SYSTEM})
+		return retryLater(codes.PermissionDenied, s.muter.Duration, fmt.Sprintf("user %s is muted for sending too many messages", name))
+	}
+	return retryLater(codes.ResourceExhausted, wait, "sending messages too fast")
+}
+
+// checkAddressRate decides whether a client at address can register an account now, and
+// counts it if it can. Registering takes from the same bucket as publishing.
+func (s *Server) checkAddressRate(address string, now time.Time) error {
+	if s.addressLimit == nil || address == "" {
+		return nil
+	}
+	if wait := s.addressLimit.wait(address, now); wait > 0 {
+		s.logger.Printf("Server %s: %s is registering accounts too fast, it can try again in %s", s.name, address, wait.Round(time.Millisecond))
+		return retryLater(codes.ResourceExhausted, wait, "registering accounts too fast")
+	}
+	s.addressLimit.take(address, now)
+	return nil
+}
+
+// retryLater returns a status with code and message that tells the client when to try again.
+func retryLater(code codes.Code, wait time.Duration, message string) error {
+	st := status.New(code, message)
+	st, _ = st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	return st.Err()
+}
@@ -0,0 +1,145 @@
+package chatserver
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 2, Burst: 3})
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if wait := l.wait("a", now); wait != 0 {
+			t.Fatalf("message %d of the burst has to wait %s", i+1, wait)
+		}
+		l.take("a", now)
+	}
+	if wait := l.wait("a", now); wait != 500*time.Millisecond {
+		t.Errorf("after the burst the wait is %s, want 500ms at 2 per second", wait)
+	}
+	if wait := l.wait("b", now); wait != 0 {
+		t.Errorf("another key has to wait %s", wait)
+	}
+	if wait := l.wait("a", now.Add(250*time.Millisecond)); wait != 250*time.Millisecond {
+		t.Errorf("halfway the wait is %s, want 250ms", wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if wait := l.wait("a", now); wait != 0 {
+		t.Fatalf("a token later the wait is %s", wait)
+	}
+	l.take("a", now)
+
+	// a long pause only fills the bucket up to the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		l.take("a", now)
+	}
+	if wait := l.wait("a", now); wait == 0 {
+		t.Error("the bucket filled up past the burst")
+	}
+}
+
+func TestLimiterBurstBelowOne(t *testing.T) {
+	for _, burst := range []int{0, -1} {
+		l := newLimiter(RateLimit{Rate: 1, Burst: burst})
+		now := time.Unix(1000, 0)
+		if wait := l.wait("a", now); wait != 0 {
+			t.Fatalf("burst %d: the first message has to wait %s", burst, wait)
+		}
+		l.take("a", now)
+		if wait := l.wait("a", now.Add(time.Second)); wait != 0 {
+			t.Errorf("burst %d: a second later the wait is %s", burst, wait)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 1, Burst: 1})
+	now := time.Unix(1000, 0)
+	l.take("old", now)
+	l.take("busy", now.Add(maxBuckets*time.Second))
+	for i := 0; len(l.buckets) < maxBuckets; i++ {
+		l.wait(fmt.Sprint("client", i), now.Add(maxBuckets*time.Second))
+	}
+	l.wait("new", now.Add(maxBuckets*time.Second))
+	if _, ok := l.buckets["old"]; ok {
+		t.Error("the bucket that filled up again was kept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("the bucket that is still empty was forgotten")
+	}
+}
+
+func TestMuter(t *testing.T) {
+	m := newMuter(Mute{Strikes: 3, Within: time.Minute, Duration: 10 * time.Second})
+	now := time.Unix(1000, 0)
+	if m.strike("a", now) || m.strike("a", now.Add(30*time.Second)) {
+		t.Fatal("muted before 3 strikes")
+	}
+	// the first strike is too long ago by now
+	if m.strike("a", now.Add(61*time.Second)) {
+		t.Fatal("muted for strikes more than a minute apart")
+	}
+	if !m.strike("a", now.Add(62*time.Second)) {
+		t.Fatal("not muted after 3 strikes within a minute")
+	}
+	now = now.Add(62 * time.Second)
+	if d := m.muted("a", now.Add(4*time.Second)); d != 6*time.Second {
+		t.Errorf("muted for another %s, want 6s", d)
+	}
+	if d := m.muted("b", now); d != 0 {
+		t.Errorf("b is muted for %s", d)
+	}
+	if d := m.muted("a", now.Add(10*time.Second)); d != 0 {
+		t.Errorf("still muted for %s after the mute ended", d)
+	}
+	// the strikes start over after a mute
+	if m.strike("a", now.Add(11*time.Second)) {
+		t.Error("muted again by the first strike after a mute")
+	}
+}
+
+func TestMuterSweep(t *testing.T) {
+	m := newMuter(Mute{Strikes: 3, Within: time.Minute, Duration: 10 * time.Second})
+	now := time.Unix(1000, 0)
+	m.strike("old", now)
+	for i := 0; i < 3; i++ {
+		m.strike("muted", now)
+	}
+	later := now.Add(maxBuckets * time.Second)
+	m.strike("recent", later)
+	for i := 0; len(m.strikes) < maxBuckets; i++ {
+		m.strike(fmt.Sprint("client", i), later)
+	}
+	m.strike("new", later)
+	if _, ok := m.strikes["old"]; ok {
+		t.Error("strikes too old to count were kept")
+	}
+	if _, ok := m.until["muted"]; ok {
+		t.Error("a mute that is over was kept")
+	}
+	if _, ok := m.strikes["recent"]; !ok {
+		t.Error("a strike that still counts was forgotten")
+	}
+	if _, ok := m.strikes["new"]; !ok {
+		t.Error("the new strike was not counted")
+	}
+}
+
+func TestPeerAddress(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5400}, "10.0.0.1"},
+		{&net.TCPAddr{IP: net.IPv6loopback, Port: 5400}, "::1"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := peerAddress(test.addr); got != test.want {
+			t.Errorf("peerAddress(%v) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
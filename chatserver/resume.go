@@ -0,0 +1,143 @@
+package chatserver
+
+import (
+	"fmt"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// maxReplay is the most missed messages sent to a resumed subscription.
+// A client that missed more only gets the newest ones, and is told about the others.
+const maxReplay = 1000
+
+// maxResumeRooms is the most rooms in the hello of a resumed subscription the server puts
+// it back in by itself.
+const maxResumeRooms = 100
+
+// departure is a subscription that ended less than the resume grace window ago. Its leave
+// message is held back until the window is over, so a client that reconnects within it
+// can take its place without anyone being told it left and came back.
+type departure struct {
+	name    string
+	rooms   []string // the rooms the subscription was in
+	message string   // the leave message to broadcast in them if the client does not come back
+	timer   *time.Timer
+}
+
+// The functions below keep s.departures. Like everything else in the server state,
+// they are only called from the loop.
+
+// depart holds back the leave message of a subscription of name for the grace window.
+func (s *Server) depart(name string, rooms []string, message string) {
+	d := &departure{name: name, rooms: rooms, message: message}
+	d.timer = time.AfterFunc(s.resumeGrace, func() { s.submit(departEvent{departure: d}) })
+	s.departures[name] = append(s.departures[name], d)
+	s.logger.Printf("User %s has %s to resume its subscription before its leaving is announced", name, s.resumeGrace)
+}
+
+// departed handles a departEvent: the client did not come back, so it has left.
+func (s *Server) departed(d *departure) {
+	if !s.forget(d) {
+		// resumed in the meantime
+		return
+	}
+	if s.closing {
+		// the farewell message already told everyone why
+		s.userLeft(d.name)
+		return
+	}
+	s.announceLeave(d.name, d.rooms, d.message)
+}
+
+// resume takes the oldest departure of name, if there is one, so a new subscription can
+// take its place. It returns nil if there is nothing to resume.
+func (s *Server) resume(name string) *departure {
+	pending := s.departures[name]
+	if len(pending) == 0 {
+		return nil
+	}
+	d := pending[0]
+	d.timer.Stop()
+	s.forget(d)
+	return d
+}
+
+// forget removes d from s.departures and reports whether it was still there.
+func (s *Server) forget(d *departure) bool {
+	pending := s.departures[d.name]
+	for i, other := range pending {
+		if other == d {
+			pending = append(pending[:i], pending[i+1:]...)
+			if len(pending) == 0 {
+				delete(s.departures, d.name)
+			} else {
+				s.departures[d.name] = pending
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// announceLeave marks a subscription of name as gone for presence, and tells the rooms it was in.
+func (s *Server) announceLeave(name string, rooms []string, message string) {
+	s.userLeft(name)
+	for _, room := range rooms {
+		s.broadcast(&gRPC.ChatMessage{ClientName: name, Message: message, Kind: gRPC.MessageKind_LEAVE, Room: room})
+	}
+}
+
+// missed returns the function that finds the messages after sequence number after that
+// sub would have received, for its backlog: at most maxReplay of them, the newest ones if
+// there are more, oldest first. The ones still in memory are picked right away, in the
+// loop, so the backlog ends exactly where the queue of sub starts. Older ones are only
+// read from the message log when the function is called by the session's goroutine, so
+// the loop does not wait for the disk.
+//
+// If not everything can be replayed, because there were too many or the older ones are
+// gone, the backlog starts with a system message saying so. It gets the sequence number
+// of the newest message left out, which the client never gets, so it comes in order and
+// is not mistaken for one the client already has.
+func (s *Server) missed(sub *session, after int64) func() []*gRPC.ChatMessage {
+	rooms := make(map[string]bool)
+	for _, room := range s.roomsOf(sub) {
+		rooms[room] = true
+	}
+	keep := func(msg *gRPC.ChatMessage) bool {
+		if msg.Recipient != "" {
+			return msg.Recipient == sub.name
+		}
+		return msg.Room == "" || rooms[msg.Room]
+	}
+	recent, left, gone := s.history.since(after, maxReplay, keep)
+	return func() []*gRPC.ChatMessage {
+		// left is the newest message that is not replayed
+		msgs := recent
+		switch {
+		case left != 0:
+			// too many in memory already, the older ones are left out too
+		case gone != 0:
+			older, dropped, err := s.history.before(after, gone, maxReplay-len(recent), keep)
+			if err != nil {
+				// anything up to the oldest one in memory could have been for the client
+				s.logger.Printf("Server %s: Cannot replay the messages before #%d to user %s: %v", s.name, gone, sub.name, err)
+				older, dropped = nil, gone-1
+			}
+			left = dropped
+			msgs = append(older, recent...)
+		}
+		s.logger.Printf("Replaying %d missed messages to user %s", len(msgs), sub.name)
+		if left == 0 {
+			return msgs
+		}
+		s.logger.Printf("User %s missed messages up to #%d that are not replayed", sub.name, left)
+		notice := &gRPC.ChatMessage{
+			ClientName: s.name,
+			Message:    fmt.Sprintf("Not every message sent while you were away could be replayed, some up to message #%d are missing. Use History to read the ones still kept.", left),
+			Kind:       gRPC.MessageKind_SYSTEM,
+			Sequence:   left,
+		}
+		return append([]*gRPC.ChatMessage{notice}, msgs...)
+	}
+}
@@ -0,0 +1,329 @@
+package chatserver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	"github.com/hannaStokes/handin3/msglog"
+)
+
+// openLog opens the message log at path, and closes it when the test ends.
+func openLog(t *testing.T, path string) *msglog.Log {
+	t.Helper()
+	l, err := msglog.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// sendAgain sends text, trying again while c is reconnecting.
+func sendAgain(t *testing.T, ctx context.Context, c *chatclient.Client, text string) {
+	t.Helper()
+	for {
+		err := c.Send(ctx, text)
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("sending %q: %v", text, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// chatUntil reads the chat messages of c until it got one with text, and fails the test
+// if c gets a message twice or out of order.
+func chatUntil(t *testing.T, ctx context.Context, c *chatclient.Client, text string) []chatclient.Message {
+	t.Helper()
+	var got []chatclient.Message
+	var last int64
+	for {
+		select {
+		case msg, ok := <-c.Messages():
+			if !ok {
+				t.Fatalf("the subscription ended: %v", c.Err())
+			}
+			if msg.Sequence <= last {
+				t.Fatalf("got #%d %q after #%d", msg.Sequence, msg.Text, last)
+			}
+			last = msg.Sequence
+			if msg.Kind != chatclient.KindChat {
+				continue
+			}
+			got = append(got, msg)
+			if msg.Text == text {
+				return got
+			}
+		case <-ctx.Done():
+			t.Fatalf("did not get %q, only %d messages", text, len(got))
+		}
+	}
+}
+
+// A client that had not taken all its messages when the server restarted resumes after the
+// last one it read, not the last one it took, so it gets nothing twice.
+func TestRestartWithMessagesWaiting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "messages.log")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	first := openLog(t, path)
+	stop := serve(t, lis, chatserver.WithMessageLog(first))
+
+	quiet := log.New(io.Discard, "", 0)
+	backoff := chatclient.WithReconnect(chatclient.Backoff{Initial: 50 * time.Millisecond, Max: 200 * time.Millisecond})
+	reader, err := chatclient.Dial(ctx, addr, chatclient.WithName("reader"), chatclient.WithBufferSize(1), chatclient.WithLogger(quiet), backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	writer, err := chatclient.Dial(ctx, addr, chatclient.WithName("writer"), chatclient.WithLogger(quiet), backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	go func() {
+		for range writer.Messages() {
+		}
+	}()
+
+	// the reader takes nothing yet, so the messages wait in its inbox
+	for i := 1; i <= 12; i++ {
+		sendAgain(t, ctx, writer, fmt.Sprint("before ", i))
+	}
+	time.Sleep(200 * time.Millisecond)
+	stop()
+	first.Close()
+
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serve(t, lis, chatserver.WithMessageLog(openLog(t, path)))()
+	sendAgain(t, ctx, writer, "after")
+
+	got := chatUntil(t, ctx, reader, "after")
+	if len(got) != 13 {
+		t.Errorf("got %d chat messages, want 13", len(got))
+	}
+}
+
+// proxy forwards connections to a server, and can cut them off to make its clients
+// reconnect without the server going away.
+type proxy struct {
+	lis    net.Listener
+	target string
+	mutex  sync.Mutex
+	conns  []net.Conn
+	down   bool
+}
+
+// newProxy starts a proxy to target on a loopback port, which stops when the test ends.
+func newProxy(t *testing.T, target string) *proxy {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &proxy{lis: lis, target: target}
+	t.Cleanup(func() {
+		lis.Close()
+		p.cut()
+	})
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go p.forward(conn)
+		}
+	}()
+	return p
+}
+
+func (p *proxy) addr() string {
+	return p.lis.Addr().String()
+}
+
+func (p *proxy) forward(conn net.Conn) {
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	p.mutex.Lock()
+	if p.down {
+		p.mutex.Unlock()
+		conn.Close()
+		server.Close()
+		return
+	}
+	p.conns = append(p.conns, conn, server)
+	p.mutex.Unlock()
+	go func() {
+		io.Copy(server, conn)
+		server.Close()
+	}()
+	io.Copy(conn, server)
+	conn.Close()
+}
+
+// cut closes every connection, and refuses new ones until up is called.
+func (p *proxy) cut() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.down = true
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *proxy) up() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.down = false
+}
+
+// waitFor waits until c is in state st.
+func waitFor(t *testing.T, ctx context.Context, c *chatclient.Client, st chatclient.State) {
+	t.Helper()
+	for c.State() != st {
+		if ctx.Err() != nil {
+			t.Fatalf("%s is still %s, want %s", c.Name(), c.State(), st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A client that lost its connection gets the messages it missed in all its rooms once, in
+// order, whether it comes back within the resume grace window or not. Only when it comes
+// back too late is it announced as gone.
+func TestResume(t *testing.T) {
+	for _, grace := range []time.Duration{time.Minute, 0} {
+		t.Run(fmt.Sprint("grace ", grace), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			addr := startServer(t, chatserver.WithResumeGrace(grace))
+			p := newProxy(t, addr)
+			w, leaves := talker(t, ctx, addr)
+			if err := w.JoinRoom(ctx, "x"); err != nil {
+				t.Fatal(err)
+			}
+
+			backoff := chatclient.WithReconnect(chatclient.Backoff{Initial: 50 * time.Millisecond, Max: 200 * time.Millisecond})
+			away, err := chatclient.Dial(ctx, p.addr(), chatclient.WithName("away"), chatclient.WithLogger(log.New(io.Discard, "", 0)), backoff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer away.Close()
+			if err := away.JoinRoom(ctx, "x"); err != nil {
+				t.Fatal(err)
+			}
+			if err := away.JoinRoom(ctx, chatclient.DefaultRoom); err != nil {
+				t.Fatal(err)
+			}
+			sendAgain(t, ctx, w, "before")
+			chatUntil(t, ctx, away, "before")
+
+			p.cut()
+			waitFor(t, ctx, away, chatclient.StateReconnecting)
+			sendAgain(t, ctx, w, "in x")
+			if err := w.JoinRoom(ctx, chatclient.DefaultRoom); err != nil {
+				t.Fatal(err)
+			}
+			sendAgain(t, ctx, w, "in general")
+			p.up()
+
+			got := chatUntil(t, ctx, away, "in general")
+			if len(got) != 2 || got[0].Text != "in x" {
+				t.Errorf("got %v, want the messages in x and in general", got)
+			}
+			select {
+			case leave := <-leaves:
+				if grace > 0 {
+					t.Errorf("got %q within the grace window", leave.Text)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if grace == 0 {
+					t.Error("nobody was told the client left")
+				}
+			}
+		})
+	}
+}
+
+// A client that was away while the server restarted gets what it missed from the log of
+// the new server, in all its rooms.
+func TestResumeAfterRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "messages.log")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	first := openLog(t, path)
+	stop := serve(t, lis, chatserver.WithMessageLog(first))
+	p := newProxy(t, addr)
+
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+	backoff := chatclient.WithReconnect(chatclient.Backoff{Initial: 50 * time.Millisecond, Max: 200 * time.Millisecond})
+	away, err := chatclient.Dial(ctx, p.addr(), chatclient.WithName("away"), chatclient.WithRoom("x"), quiet, backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer away.Close()
+	if err := away.JoinRoom(ctx, "y"); err != nil {
+		t.Fatal(err)
+	}
+	writer, err := chatclient.Dial(ctx, addr, chatclient.WithName("writer"), chatclient.WithRoom("x"), quiet, backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	go func() {
+		for range writer.Messages() {
+		}
+	}()
+	sendAgain(t, ctx, writer, "before")
+	chatUntil(t, ctx, away, "before")
+
+	p.cut()
+	waitFor(t, ctx, away, chatclient.StateReconnecting)
+	stop()
+	first.Close()
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serve(t, lis, chatserver.WithMessageLog(openLog(t, path)))()
+	sendAgain(t, ctx, writer, "in x")
+	if err := writer.JoinRoom(ctx, "y"); err != nil {
+		t.Fatal(err)
+	}
+	sendAgain(t, ctx, writer, "in y")
+	p.up()
+
+	got := chatUntil(t, ctx, away, "in y")
+	if len(got) != 2 || got[0].Text != "in x" {
+		t.Errorf("got %v, want the messages in x and in y", got)
+	}
+}
@@ -0,0 +1,221 @@
+package chatserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRoom is the room clients are put in when they subscribe without naming one,
+// and where messages published without a room are sent.
+const DefaultRoom = "general"
+
+// maxRoomName is the longest room name, in characters.
+const maxRoomName = 32
+
+// roomName checks a room name from a request. An empty name means DefaultRoom if
+// allowDefault is set, otherwise it is an error.
+func roomName(room string, allowDefault bool) (string, error) {
+	if room == "" && allowDefault {
+		return DefaultRoom, nil
+	}
+	switch {
+	case room == "":
+		return "", status.Error(codes.InvalidArgument, "room name cannot be empty")
+	case utf8.RuneCountInString(room) > maxRoomName:
+		return "", status.Errorf(codes.InvalidArgument, "room name cannot be longer than %d characters", maxRoomName)
+	case strings.IndexFunc(room, unicode.IsSpace) >= 0:
+		return "", status.Error(codes.InvalidArgument, "room name cannot contain spaces")
+	}
+	return room, nil
+}
+
+// JoinRoom adds every subscription of the client to a room, so it receives the messages sent there.
+func (s *Server) JoinRoom(ctx context.Context, req *gRPC.RoomRequest) (*gRPC.RoomReply, error) {
+	room, err := roomName(req.Room, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCaller(ctx, req.ClientName); err != nil {
+		return nil, err
+	}
+	s.logger.Printf("User %s is joining room %s", req.ClientName, room)
+	done := make(chan roomResult, 1)
+	if !s.submit(joinRoomEvent{name: req.ClientName, room: room, timestamp: req.Timestamp, done: done}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	res := <-done
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &gRPC.RoomReply{Room: room, Timestamp: res.timestamp}, nil
+}
+
+// LeaveRoom removes every subscription of the client from a room.
+func (s *Server) LeaveRoom(ctx context.Context, req *gRPC.RoomRequest) (*gRPC.RoomReply, error) {
+	room, err := roomName(req.Room, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCaller(ctx, req.ClientName); err != nil {
+		return nil, err
+	}
+	s.logger.Printf("User %s is leaving room %s", req.ClientName, room)
+	done := make(chan roomResult, 1)
+	if !s.submit(leaveRoomEvent{name: req.ClientName, room: room, timestamp: req.Timestamp, done: done}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	res := <-done
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &gRPC.RoomReply{Room: room, Timestamp: res.timestamp}, nil
+}
+
+// ListRooms returns every room that has members, and the default room even if it is empty.
+func (s *Server) ListRooms(ctx context.Context, req *gRPC.ListRoomsRequest) (*gRPC.RoomList, error) {
+	rooms := make(chan []*gRPC.RoomInfo, 1)
+	if !s.submit(listRoomsEvent{rooms: rooms}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return &gRPC.RoomList{Rooms: <-rooms}, nil
+}
+
+// The functions below keep the per-room subscriber sets in s.rooms. Like everything else
+// in the server state, they are only called from the loop.
+
+// addToRoom puts sub in room, making the room if it did not exist yet.
+// It returns false if sub already was in it.
+func (s *Server) addToRoom(room string, sub *session) bool {
+	members := s.rooms[room]
+	if members == nil {
+		members = make(map[*session]struct{})
+		s.rooms[room] = members
+	}
+	if _, ok := members[sub]; ok {
+		return false
+	}
+	members[sub] = struct{}{}
+	return true
+}
+
+// removeFromRoom takes sub out of room and removes the room once nobody is left in it.
+// It returns false if sub was not in it.
+func (s *Server) removeFromRoom(room string, sub *session) bool {
+	members := s.rooms[room]
+	if _, ok := members[sub]; !ok {
+		return false
+	}
+	delete(members, sub)
+	if len(members) == 0 {
+		delete(s.rooms, room)
+	}
+	return true
+}
+
+// roomsOf returns the sorted names of the rooms sub is in.
+func (s *Server) roomsOf(sub *session) []string {
+	var rooms []string
+	for room, members := range s.rooms {
+		if _, ok := members[sub]; ok {
+			rooms = append(rooms, room)
+		}
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// sessionsOf returns every subscription of the client called name.
+func (s *Server) sessionsOf(name string) []*session {
+	var subs []*session
+	for _, sub := range s.subscribers {
+		if sub.name == name {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// inRoom reports whether any subscription of the client called name is in room.
+func (s *Server) inRoom(name, room string) bool {
+	for sub := range s.rooms[room] {
+		if sub.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// joinRoom handles a joinRoomEvent and returns an error to send back to the client, if any.
+func (s *Server) joinRoom(name, room string) error {
+	subs := s.sessionsOf(name)
+	if len(subs) == 0 {
+		return status.Errorf(codes.FailedPrecondition, "user %s is not subscribed", name)
+	}
+	joined := false
+	for _, sub := range subs {
+		if s.addToRoom(room, sub) {
+			joined = true
+		}
+	}
+	if !joined {
+		// already there, nothing to tell anyone
+		return nil
+	}
+	s.logger.Printf("User %s joined room %s.\n           Number of subscriptions in the room: %d \n", name, room, len(s.rooms[room]))
+	msg := fmt.Sprintf("User %s joined room %s", name, room)
+	s.broadcast(&gRPC.ChatMessage{ClientName: name, Message: msg, Kind: gRPC.MessageKind_JOIN, Room: room})
+	return nil
+}
+
+// leaveRoom handles a leaveRoomEvent and returns an error to send back to the client, if any.
+func (s *Server) leaveRoom(name, room string) error {
+	left := false
+	for _, sub := range s.sessionsOf(name) {
+		if s.removeFromRoom(room, sub) {
+			left = true
+		}
+	}
+	if !left {
+		return status.Errorf(codes.FailedPrecondition, "user %s is not in room %s", name, room)
+	}
+	s.logger.Printf("User %s left room %s.\n           Number of subscriptions in the room: %d \n", name, room, len(s.rooms[room]))
+	msg := fmt.Sprintf("User %s left room %s", name, room)
+	s.broadcast(&gRPC.ChatMessage{ClientName: name, Message: msg, Kind: gRPC.MessageKind_LEAVE, Room: room})
+	return nil
+}
+
+// listRooms handles a listRoomsEvent.
+func (s *Server) listRooms() []*gRPC.RoomInfo {
+	names := make([]string, 0, len(s.rooms)+1)
+	for room := range s.rooms {
+		names = append(names, room)
+	}
+	if _, ok := s.rooms[DefaultRoom]; !ok {
+		names = append(names, DefaultRoom)
+	}
+	sort.Strings(names)
+
+	rooms := make([]*gRPC.RoomInfo, len(names))
+	for i, room := range names {
+		seen := make(map[string]bool)
+		info := &gRPC.RoomInfo{Name: room}
+		for sub := range s.rooms[room] {
+			if !seen[sub.name] {
+				seen[sub.name] = true
+				info.Members = append(info.Members, sub.name)
+			}
+		}
+		sort.Strings(info.Members)
+		rooms[i] = info
+	}
+	return rooms
+}
@@ -0,0 +1,519 @@
+// Package chatserver contains the ChittyChat gRPC service, so it can be embedded
+// in other binaries and tests instead of only being run through server/server.go.
+package chatserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	// this has to be the same as the go.mod module,
+	// followed by the path to the folder the proto file is in.
+	"github.com/hannaStokes/handin3/clock"
+	"github.com/hannaStokes/handin3/msglog"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type Server struct {
+	gRPC.UnimplementedChittyChatServer        // You need this line if you have a server. "ChittyChat" should be the equivalent name of your service
+	name                               string // Not required but useful if you want to name your server
+	port                               string // Not required but useful if your server needs to know what port it's listening to
+
+	// subscribers, rooms, the clocks and sequence are only touched by the loop goroutine, see loop.go.
+	subscribers []*session
+	lamport     *clock.Lamport // value that clients can increment.
+	logical     clock.Clock    // the extra clock chosen with WithClock, nil if it is just Lamport.
+	sequence    int64          // sequence number of the last broadcast message.
+	history     history        // the newest broadcast messages, added to by the loop, see history.go.
+	events      chan any
+	closing     bool          // set by the shutdown event, stops leave messages from being broadcast.
+	stats       serverStats   // counted by the loop and logged on shutdown.
+	stopped     chan struct{} // closed once the gRPC server has stopped, which ends the loop.
+
+	// the subscribers in each room, see rooms.go. Rooms without subscribers are removed.
+	rooms map[string]map[*session]struct{}
+	// the connected users by name and the WatchPresence streams, see presence.go.
+	users    map[string]*user
+	watchers map[*watcher]struct{}
+	// subscriptions that ended but can still be resumed, by client name, see resume.go.
+	departures map[string][]*departure
+	// recently accepted message ids, nil if messages are not deduplicated, see dedup.go.
+	dedup *dedup
+	// token buckets per client name and per address, and who is muted, see ratelimit.go.
+	// Each is nil if it is not used.
+	clientLimit  *limiter
+	addressLimit *limiter
+	muter        *muter
+
+	queueSize    int                // how many messages each subscriber can have waiting.
+	slowPolicy   SlowConsumerPolicy // what to do when a subscriber's queue is full.
+	blockTimeout time.Duration      // how long BlockWithTimeout waits for room in a queue.
+	clockKind    clock.Kind         // set by WithClock.
+	messageLog   *msglog.Log        // where broadcast messages are persisted, nil to keep them only in memory.
+	persister    *persister         // hands broadcast messages to the goroutine writing the log, see persist.go.
+	heartbeat    time.Duration      // how often Chat streams are pinged, 0 to not ping them.
+	resumeGrace  time.Duration      // how long leaving is not announced, so the client can resume.
+	dedupWindow  time.Duration      // how long message ids are remembered, 0 to not deduplicate.
+	maxLength    int                // the longest chat message accepted, in characters.
+	auth         AuthFunc           // who is calling, nil to believe the client names in requests. See auth.go.
+	accounts     *Accounts          // for Register and Login, nil if the server has no accounts. See accounts.go.
+	misses       int                // heartbeats a client can miss before it is disconnected.
+
+	logger      *log.Logger
+	grpcOptions []grpc.ServerOption
+	grpcServer  *grpc.Server
+	quit        chan struct{} // closed by Shutdown so open subscriptions can return.
+	quitOnce    sync.Once
+	stopOnce    sync.Once
+}
+
+// Option configures a Server created by NewServer.
+type Option func(*Server)
+
+// WithName sets the name the server reports in logs and in ChatAccept replies.
+func WithName(name string) Option {
+	return func(s *Server) { s.name = name }
+}
+
+// WithPort records the port the server is listening on. It is only used for logging,
+// the actual address is decided by the listener given to Serve.
+func WithPort(port string) Option {
+	return func(s *Server) { s.port = port }
+}
+
+// WithLogger makes the server log to l instead of the standard logger.
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) { s.logger = l }
+}
+
+// WithQueueSize sets how many broadcast messages can wait to be sent to each session.
+func WithQueueSize(n int) Option {
+	return func(s *Server) { s.queueSize = n }
+}
+
+// WithSlowConsumerPolicy sets what happens when a subscriber's queue is full. Defaults to
+// Disconnect. The timeout is only used by BlockWithTimeout, which stalls the whole server
+// for up to that long every time a queue is full, so one client that stops reading slows
+// down everyone.
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy, timeout time.Duration) Option {
+	return func(s *Server) {
+		s.slowPolicy = policy
+		s.blockTimeout = timeout
+	}
+}
+
+// WithClock chooses the logical clock of the server. The Lamport clock is always kept for
+// the timestamp of every message; choosing a vector or hybrid clock keeps that clock as well.
+// Join, leave and system messages then carry its time, and every time is logged.
+// Chat messages always carry the time their sender gave them, with or without this option.
+func WithClock(kind clock.Kind) Option {
+	return func(s *Server) { s.clockKind = kind }
+}
+
+// WithMessageLog makes the server append every broadcast message to l. The messages already
+// in l are recovered when the server is made, so the sequence numbers and the Lamport clock
+// continue from the last persisted message instead of from 0.
+// History pages older than the messages the server keeps in memory are read from l.
+// The server does not close l, that is left to the caller after Shutdown.
+func WithMessageLog(l *msglog.Log) Option {
+	return func(s *Server) { s.messageLog = l }
+}
+
+// WithHistorySize sets how many of the newest messages the server keeps in memory for
+// History and for resumed subscriptions. Defaults to DefaultHistorySize, and is never less
+// than the most messages a resumed subscription gets replayed. Older messages are read from
+// the message log, or are gone if there is none.
+func WithHistorySize(n int) Option {
+	return func(s *Server) { s.history.size = n }
+}
+
+// WithKeepalive sets the gRPC keepalive parameters of the server, so gRPC itself closes
+// connections that have gone silent. Clients that ping more often than policy allows are
+// disconnected, so the clients' keepalive time must not be shorter than policy.MinTime.
+func WithKeepalive(params keepalive.ServerParameters, policy keepalive.EnforcementPolicy) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.KeepaliveParams(params), grpc.KeepaliveEnforcementPolicy(policy))
+	}
+}
+
+// WithHeartbeat makes the server ping every Chat stream once per interval. A client the
+// server has heard nothing from for misses intervals is disconnected, and the rooms it was
+// in are told it timed out. Subscribe streams cannot answer pings, so for those only
+// WithKeepalive helps.
+func WithHeartbeat(interval time.Duration, misses int) Option {
+	return func(s *Server) {
+		s.heartbeat = interval
+		s.misses = misses
+	}
+}
+
+// WithResumeGrace sets how long after a subscription ends its client can resume it without
+// the rooms it was in being told it left, and it being told it joined again. During that
+// time the client still counts as online and direct messages to it are kept for it.
+// Without this option leaving is announced straight away. Missed messages are replayed
+// to resuming clients either way.
+func WithResumeGrace(grace time.Duration) Option {
+	return func(s *Server) { s.resumeGrace = grace }
+}
+
+// WithDedupWindow sets how long the server remembers the ids of accepted messages. A message
+// with the same id from the same sender within that time is not broadcast again; the sender
+// gets the ChatAccept of the first one instead. Defaults to 5 minutes, 0 turns it off.
+func WithDedupWindow(window time.Duration) Option {
+	return func(s *Server) { s.dedupWindow = window }
+}
+
+// WithMaxMessageLength sets the longest chat message the server accepts, in characters.
+// Longer messages are rejected with a ResourceExhausted status. Defaults to DefaultMaxMessageLength.
+func WithMaxMessageLength(n int) Option {
+	return func(s *Server) { s.maxLength = n }
+}
+
+// WithRateLimit limits how fast messages can be published by each client name, and by all
+// clients from the same address together. A message over either limit is refused with a
+// ResourceExhausted status that says when to try again. A limit with a Rate of 0 is not used.
+// Without this option there are no limits.
+func WithRateLimit(perClient, perAddress RateLimit) Option {
+	return func(s *Server) {
+		if perClient.Rate > 0 {
+			s.clientLimit = newLimiter(perClient)
+		}
+		if perAddress.Rate > 0 {
+			s.addressLimit = newLimiter(perAddress)
+		}
+	}
+}
+
+// WithMute mutes clients that keep going over the rate limit, see Mute. While muted, their
+// messages are refused with a PermissionDenied status. It only has an effect together with WithRateLimit.
+func WithMute(mute Mute) Option {
+	return func(s *Server) {
+		if mute.Strikes > 0 && mute.Duration > 0 {
+			s.muter = newMuter(mute)
+		}
+	}
+}
+
+// WithAuth makes every call go through auth, which has to say who the caller is, and
+// makes the server refuse requests that claim to be from another client with PermissionDenied.
+// Callers auth refuses get the error it returns. See TokenAuth for bearer tokens.
+func WithAuth(auth AuthFunc) Option {
+	return func(s *Server) { s.auth = auth }
+}
+
+// WithAccounts lets clients register accounts in a and log in to them with Register and
+// Login. To make clients log in, also use WithAuth with a.Auth(), on its own or in AnyAuth;
+// in AnyAuth, see Accounts for keeping the names of the other ways to log in from being registered.
+func WithAccounts(a *Accounts) Option {
+	return func(s *Server) { s.accounts = a }
+}
+
+// WithGRPCOptions passes extra options on to grpc.NewServer.
+func WithGRPCOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) { s.grpcOptions = append(s.grpcOptions, opts...) }
+}
+
+// NewServer makes a new server instance. Without options it is named "default",
+// logs through the standard logger and gives every subscriber a queue of 64 messages,
+// waiting up to a second for room in a full queue.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		name:         "default",
+		lamport:      clock.NewLamport(),
+		clockKind:    clock.KindLamport,
+		subscribers:  make([]*session, 0),
+		rooms:        make(map[string]map[*session]struct{}),
+		users:        make(map[string]*user),
+		watchers:     make(map[*watcher]struct{}),
+		departures:   make(map[string][]*departure),
+		queueSize:    64,
+		history:      history{size: DefaultHistorySize},
+		slowPolicy:   Disconnect,
+		blockTimeout: time.Second,
+		dedupWindow:  5 * time.Minute,
+		maxLength:    DefaultMaxMessageLength,
+		logger:       log.Default(),
+		events:       make(chan any),
+		stopped:      make(chan struct{}),
+		quit:         make(chan struct{}),
+		stats:        serverStats{started: time.Now()},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.misses < 1 {
+		s.misses = 1
+	}
+	if s.dedupWindow > 0 {
+		s.dedup = newDedup(s.dedupWindow)
+	}
+	if s.clockKind != clock.KindLamport {
+		// for vector clocks the server is a process of its own, named so it cannot clash with a client
+		logical, err := clock.New(s.clockKind, clock.ServerID(s.name))
+		if err != nil {
+			s.logger.Printf("Server %s: %v, using only the Lamport clock", s.name, err)
+		}
+		s.logical = logical
+	}
+
+	// enough to replay what a resumed subscription missed
+	s.history.size = max(s.history.size, maxReplay)
+	if s.messageLog != nil {
+		s.history.log = s.messageLog
+		s.recoverLog()
+		s.persister = newPersister()
+	}
+
+	// makes gRPC server using the options
+	grpcOptions := s.grpcOptions
+	if s.auth != nil {
+		grpcOptions = append(grpcOptions, grpc.ChainUnaryInterceptor(s.authUnary), grpc.ChainStreamInterceptor(s.authStream))
+	}
+	s.grpcServer = grpc.NewServer(grpcOptions...)
+	gRPC.RegisterChittyChatServer(s.grpcServer, s) //Registers the server to the gRPC server.
+
+	go s.loop()
+	if s.persister != nil {
+		go s.persist()
+	}
+	return s
+}
+
+// recoverLog continues the sequence numbers and the Lamport clock from the last message in the message log.
+func (s *Server) recoverLog() {
+	if n := s.messageLog.Truncated(); n > 0 {
+		s.logger.Printf("Server %s: Removed %d bytes of an incomplete message at the end of %s", s.name, n, s.messageLog.Path())
+	}
+	// only the newest messages are kept in memory, the history reads older ones from the file
+	var last *gRPC.ChatMessage
+	skip := s.messageLog.Len() - s.history.size
+	err := s.messageLog.Scan(func(entry *gRPC.LogEntry) bool {
+		last = entry.Message
+		if skip > 0 {
+			skip--
+			s.history.trimmed = true
+			return true
+		}
+		s.history.add(entry.Message)
+		return true
+	})
+	if err != nil {
+		s.logger.Printf("Server %s: %v", s.name, err)
+	}
+	if last == nil {
+		return
+	}
+	s.sequence = last.Sequence
+	s.lamport.Observe(clock.LamportTime(last.Timestamp))
+	s.logger.Printf("Server %s: Recovered %d messages from %s, kept the newest %d in memory.\n           Continuing after message #%d at Lamport time %d \n", s.name, s.messageLog.Len(), s.messageLog.Path(), len(s.history.messages), s.sequence, s.lamport.Now().Counter)
+}
+
+// Serve accepts connections on lis until Shutdown is called.
+// It blocks, so run it in its own goroutine if the caller needs to do anything else.
+func (s *Server) Serve(lis net.Listener) error {
+	s.logger.Printf("Server %s: Listening at %v\n\n", s.name, lis.Addr())
+	if err := s.grpcServer.Serve(lis); err != nil {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
+
+// Shutdown tells every subscriber that the server is shutting down, sends them what is
+// left in their queues, ends their subscriptions and waits for the gRPC server to stop.
+// If ctx is done before that happens, the remaining connections are closed forcefully
+// and ctx.Err() is returned. Final statistics are logged either way.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.quitOnce.Do(func() {
+		s.logger.Printf("Server %s: Shutting down", s.name)
+		done := make(chan struct{})
+		if s.submit(shutdownEvent{done: done}) {
+			<-done
+		}
+		close(s.quit)
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-stopped
+		err = ctx.Err()
+	}
+	// every handler has returned, so nothing is left to submit events
+	stats := make(chan serverStats, 1)
+	if s.submit(statsEvent{stats: stats}) {
+		s.logStats(<-stats)
+	}
+	s.stopOnce.Do(func() { close(s.stopped) })
+	return err
+}
+
+// The method format can be found in the pb.go file. If the format is wrong, the server type will give an error.
+func (s *Server) Subscribe(in *gRPC.SubMessage, stream gRPC.ChittyChat_SubscribeServer) error {
+	s.logger.Printf("User: %s is subscribing", in.ClientName)
+	sub, err := s.join(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	sub.start(stream.Send)
+
+	select {
+	case <-stream.Context().Done():
+		sub.end(nil)
+	case <-s.quit:
+		sub.drain()
+	case <-sub.done:
+	}
+	return s.leave(sub)
+}
+
+// join checks the SubMessage of a new subscription, from Subscribe or the hello of Chat,
+// and adds a session for it. The caller has to start the session.
+func (s *Server) join(ctx context.Context, in *gRPC.SubMessage) (*session, error) {
+	select {
+	case <-s.quit:
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	default:
+	}
+	if name, ok := caller(ctx); ok && in.ClientName == "" {
+		in.ClientName = name
+	}
+	if err := checkName(in.ClientName); err != nil {
+		return nil, err
+	}
+	if err := s.checkCaller(ctx, in.ClientName); err != nil {
+		return nil, err
+	}
+	sent, err := decodeClock(in.Clock)
+	if err != nil {
+		return nil, err
+	}
+	room, err := roomName(in.Room, true)
+	if err != nil {
+		return nil, err
+	}
+	if in.LastSequence < 0 {
+		return nil, status.Error(codes.InvalidArgument, "last sequence cannot be negative")
+	}
+	var rooms []string
+	for _, other := range in.Rooms {
+		if len(rooms) == maxResumeRooms {
+			// the client joins the rest again itself
+			break
+		}
+		other, err := roomName(other, false)
+		if err != nil {
+			return nil, err
+		}
+		if other != room && !slices.Contains(rooms, other) {
+			rooms = append(rooms, other)
+		}
+	}
+	sub := newSession(in.ClientName, s.queueSize)
+	done := make(chan struct{})
+	if !s.submit(joinEvent{sub: sub, room: room, timestamp: in.Timestamp, clock: sent, lastSequence: in.LastSequence, rooms: rooms, done: done}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	// the missed messages have to be in place before the session starts
+	<-done
+	return sub, nil
+}
+
+// leave waits for a session that was told to end or drain, removes it, and returns
+// why it ended. It does not wait for the goroutine sending to the client, which can be
+// stuck until the handler returns, see session.
+func (s *Server) leave(sub *session) error {
+	err := sub.wait()
+	if err != nil {
+		s.logger.Printf("Evicting user %s: %v", sub.name, err)
+	}
+	s.submit(leaveEvent{sub: sub})
+	return err
+}
+
+// IncreaseLamport moves the server clock past timestamp, as done when receiving an event,
+// and returns the new Lamport time of the server.
+func (s *Server) IncreaseLamport(timestamp int64) int64 {
+	now := make(chan int64, 1)
+	if !s.submit(tickEvent{timestamp: timestamp, now: now}) {
+		return 0
+	}
+	return <-now
+}
+
+func (s *Server) Publish(ctx context.Context, ChatMessage *gRPC.ChatMessage) (*gRPC.ChatAccept, error) {
+	s.logger.Printf("Message being published")
+	return s.publish(ctx, ChatMessage, false)
+}
+
+// SendDirect sends a private message to every subscription of the recipient.
+func (s *Server) SendDirect(ctx context.Context, ChatMessage *gRPC.ChatMessage) (*gRPC.ChatAccept, error) {
+	s.logger.Printf("Direct message being sent from %s to %s", ChatMessage.ClientName, ChatMessage.Recipient)
+	return s.publish(ctx, ChatMessage, true)
+}
+
+// publish hands a message from a client to the loop, to be broadcast in its room or,
+// if direct is set, sent to its recipient. Used by Publish, SendDirect and Chat.
+func (s *Server) publish(ctx context.Context, in *gRPC.ChatMessage, direct bool) (*gRPC.ChatAccept, error) {
+	sent, err := decodeClock(in.Clock)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := caller(ctx); ok && in.ClientName == "" {
+		in.ClientName = name
+	}
+	if err := s.checkMessage(in); err != nil {
+		return nil, err
+	}
+	if err := s.checkCaller(ctx, in.ClientName); err != nil {
+		return nil, err
+	}
+	message := &gRPC.ChatMessage{ClientName: in.ClientName, Timestamp: in.Timestamp, Message: in.Message, Kind: gRPC.MessageKind_CHAT, Clock: in.Clock, MessageId: in.MessageId}
+	// whatever the client put in account, only the server can vouch for who sent it
+	message.Account, _ = caller(ctx)
+	if direct {
+		if in.Recipient == "" {
+			return nil, invalid("recipient", "a direct message needs a recipient")
+		}
+		message.Kind = gRPC.MessageKind_DIRECT
+		message.Recipient = in.Recipient
+	} else {
+		room, err := roomName(in.Room, true)
+		if err != nil {
+			return nil, err
+		}
+		message.Room = room
+	}
+	accepted := make(chan publishResult, 1)
+	var address string
+	if p, ok := peer.FromContext(ctx); ok {
+		address = peerAddress(p.Addr)
+	}
+	if !s.submit(publishEvent{message: message, clock: sent, address: address, accepted: accepted}) {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	select {
+	case res := <-accepted:
+		return res.accept, res.err
+	case <-s.stopped:
+		// stopped before the message was on disk
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+}
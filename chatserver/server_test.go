@@ -0,0 +1,224 @@
+package chatserver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+)
+
+// startServer serves a new server on a loopback port and returns its address.
+// The server is shut down when the test ends.
+func startServer(t *testing.T, opts ...chatserver.Option) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(serve(t, lis, opts...))
+	return lis.Addr().String()
+}
+
+// serve serves a new server on lis, and returns the function that shuts it down.
+func serve(t *testing.T, lis net.Listener, opts ...chatserver.Option) func() {
+	t.Helper()
+	s := chatserver.NewServer(append([]chatserver.Option{chatserver.WithLogger(log.New(io.Discard, "", 0))}, opts...)...)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(lis) }()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	}
+}
+
+// Many clients publishing at the same time all get every message, in the same order.
+// Run it with -race, that is what it is for.
+func TestConcurrentClients(t *testing.T) {
+	clients, messages := 200, 3
+	if testing.Short() {
+		clients = 20
+	}
+	// big enough queues that no client is too slow for the burst, which is not what this tests
+	addr := startServer(t, chatserver.WithQueueSize(clients*messages*2))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	quiet := log.New(io.Discard, "", 0)
+
+	// everyone is subscribed before anyone sends, so everyone can get everything
+	all := make([]*chatclient.Client, clients)
+	var dialing sync.WaitGroup
+	for i := range all {
+		dialing.Add(1)
+		go func(i int) {
+			defer dialing.Done()
+			c, err := chatclient.Dial(ctx, addr, chatclient.WithName(fmt.Sprint("c", i)), chatclient.WithLogger(quiet))
+			if err != nil {
+				t.Errorf("client %d: %v", i, err)
+				return
+			}
+			all[i] = c
+		}(i)
+	}
+	dialing.Wait()
+	if t.Failed() {
+		return
+	}
+	defer func() {
+		for _, c := range all {
+			c.Close()
+		}
+	}()
+
+	received := make([][]int64, clients) // sequence numbers of the chat messages each client got
+	var receiving, sending sync.WaitGroup
+	for i, c := range all {
+		receiving.Add(1)
+		go func(i int, c *chatclient.Client) {
+			defer receiving.Done()
+			var last int64
+			for msg := range c.Messages() {
+				if msg.Sequence <= last {
+					t.Errorf("client %d got #%d after #%d", i, msg.Sequence, last)
+				}
+				last = msg.Sequence
+				if msg.Kind == chatclient.KindChat {
+					received[i] = append(received[i], msg.Sequence)
+					if len(received[i]) == clients*messages {
+						return
+					}
+				}
+			}
+			t.Errorf("client %d: the subscription ended after %d messages: %v", i, len(received[i]), c.Err())
+		}(i, c)
+
+		sending.Add(1)
+		go func(i int, c *chatclient.Client) {
+			defer sending.Done()
+			for j := 0; j < messages; j++ {
+				if err := c.Send(ctx, fmt.Sprintf("message %d of c%d", j, i)); err != nil {
+					t.Errorf("client %d: %v", i, err)
+					return
+				}
+			}
+		}(i, c)
+	}
+	sending.Wait()
+	receiving.Wait()
+
+	for i := range received {
+		if fmt.Sprint(received[i]) != fmt.Sprint(received[0]) {
+			t.Fatalf("client %d got the messages in another order than client 0", i)
+		}
+	}
+}
+
+func TestSendDirect(t *testing.T) {
+	addr := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := log.New(io.Discard, "", 0)
+	dial := func(name string) *chatclient.Client {
+		c, err := chatclient.Dial(ctx, addr, chatclient.WithName(name), chatclient.WithLogger(quiet))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	a, b, eve := dial("a"), dial("b"), dial("eve")
+	if err := a.SendDirect(ctx, "b", "for b only"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(ctx, "for everyone"); err != nil {
+		t.Fatal(err)
+	}
+	// b gets the direct message, eve only the one for everyone after it
+	for msg := range b.Messages() {
+		if msg.Kind == chatclient.KindDirect {
+			if msg.Text != "for b only" || msg.ClientName != "a" {
+				t.Errorf("b got %+v", msg)
+			}
+			break
+		}
+	}
+	for msg := range eve.Messages() {
+		if msg.Kind == chatclient.KindDirect {
+			t.Fatalf("eve got the direct message %+v", msg)
+		}
+		if msg.Kind == chatclient.KindChat {
+			break
+		}
+	}
+}
+
+// OnJoin and OnLeave are called for the room joins and leaves a client sees too, with the
+// room, and for every room a user that leaves the server was in.
+func TestJoinAndLeaveCallbacks(t *testing.T) {
+	addr := startServer(t, chatserver.WithResumeGrace(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+	events := make(chan string, 16)
+	watch := func(what string) func(name, room string) {
+		return func(name, room string) {
+			if name == "other" {
+				events <- fmt.Sprint(what, " ", room)
+			}
+		}
+	}
+	watcher, err := chatclient.Dial(ctx, addr, chatclient.WithName("watcher"), chatclient.OnJoin(watch("join")), chatclient.OnLeave(watch("leave")), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	go func() {
+		for range watcher.Messages() {
+		}
+	}()
+	if err := watcher.JoinRoom(ctx, "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := chatclient.Dial(ctx, addr, chatclient.WithName("other"), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, room := range []string{"x", "y"} {
+		if err := other.JoinRoom(ctx, room); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := other.LeaveRoom(ctx, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.JoinRoom(ctx, "x"); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	// the watcher is not in y, so it hears nothing about it
+	want := []string{"join general", "join x", "leave x", "join x", "leave general", "leave x"}
+	for i, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Fatalf("event %d is %q, want %q", i+1, got, w)
+			}
+		case <-ctx.Done():
+			t.Fatalf("got only %d events", i)
+		}
+	}
+}
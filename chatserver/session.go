@@ -0,0 +1,124 @@
+package chatserver
+
+import (
+	"sync"
+	"sync/atomic"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// session is one subscription of one client. Broadcast messages are put in queue by the
+// loop and sent to the client by the session's own goroutine, started with start.
+// The session ends when the client disconnects, the client is too slow or sending to it
+// fails; after that its goroutine stops and nothing more is queued. When the server shuts
+// down the session is drained instead, so messages already queued are still sent.
+//
+// A client that stops reading leaves the goroutine stuck in a send until the stream is
+// closed, and gRPC only closes it once the handler has returned. So handlers do not wait
+// for the goroutine, only for the session to end; the send then fails and the goroutine
+// returns without sending anything else.
+type session struct {
+	name    string
+	queue   chan *gRPC.ChatMessage
+	dropped atomic.Int64               // messages that never made it into queue
+	backlog func() []*gRPC.ChatMessage // finds the missed messages of a resumed subscription, sent before queue. Set by the loop before start
+
+	done    chan struct{} // closed by end
+	endOnce sync.Once
+	err     error // why the session ended, nil for a normal disconnect. Set before done is closed.
+
+	draining  chan struct{} // closed by drain
+	drainOnce sync.Once
+}
+
+func newSession(name string, size int) *session {
+	return &session{
+		name:     name,
+		queue:    make(chan *gRPC.ChatMessage, size),
+		done:     make(chan struct{}),
+		draining: make(chan struct{}),
+	}
+}
+
+// start runs the goroutine sending queued messages with send until the session ends.
+// send is the Send method of a Subscribe stream, or sends on a Chat stream.
+func (sub *session) start(send func(*gRPC.ChatMessage) error) {
+	go func() {
+		var backlog []*gRPC.ChatMessage
+		if sub.backlog != nil {
+			backlog = sub.backlog()
+		}
+		for _, msg := range backlog {
+			select {
+			case <-sub.done:
+				return
+			default:
+			}
+			if err := send(msg); err != nil {
+				sub.end(err)
+				return
+			}
+		}
+		for {
+			select {
+			case <-sub.done:
+				return
+			//Don't put logs or prints in here! This is run once for all clients, everytime something is broadcast!
+			case msg := <-sub.queue:
+				if sub.ended() {
+					// both were ready, and the session may be over because its handler returned
+					return
+				}
+				// the message was stamped by broadcast, so every client gets the same timestamp
+				if err := send(msg); err != nil {
+					sub.end(err)
+					return
+				}
+			case <-sub.draining:
+				// send whatever is left in the queue, then end
+				for {
+					select {
+					case msg := <-sub.queue:
+						if err := send(msg); err != nil {
+							sub.end(err)
+							return
+						}
+					default:
+						sub.end(nil)
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// drain makes the session send the messages already in its queue and then end.
+func (sub *session) drain() {
+	sub.drainOnce.Do(func() { close(sub.draining) })
+}
+
+// end stops the session. Only the first call has any effect, so err is the first reason.
+func (sub *session) end(err error) {
+	sub.endOnce.Do(func() {
+		sub.err = err
+		close(sub.done)
+	})
+}
+
+// ended reports whether the session has ended.
+func (sub *session) ended() bool {
+	select {
+	case <-sub.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait blocks until the session has ended, and reports why. A drained session ends once
+// everything in its queue is sent, any other one ends straight away.
+func (sub *session) wait() error {
+	<-sub.done
+	return sub.err
+}
@@ -0,0 +1,329 @@
+package chatserver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// rawClient connects to addr without chatclient, for clients that misbehave.
+func rawClient(t *testing.T, addr string) gRPC.ChittyChatClient {
+	t.Helper()
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gRPC.NewChittyChatClient(conn)
+}
+
+// talker dials a client that reads everything it gets, and returns it with a channel of
+// the leave messages it got.
+func talker(t *testing.T, ctx context.Context, addr string, opts ...chatclient.Option) (*chatclient.Client, <-chan chatclient.Message) {
+	t.Helper()
+	opts = append([]chatclient.Option{chatclient.WithName("talker"), chatclient.WithLogger(log.New(io.Discard, "", 0))}, opts...)
+	c, err := chatclient.Dial(ctx, addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	leaves := make(chan chatclient.Message, 16)
+	go func() {
+		for msg := range c.Messages() {
+			if msg.Kind == chatclient.KindLeave {
+				leaves <- msg
+			}
+		}
+	}()
+	return c, leaves
+}
+
+// flood sends n of the longest messages there are, to fill up whatever buffers a client
+// that does not read has.
+func flood(t *testing.T, ctx context.Context, c *chatclient.Client, n int) {
+	t.Helper()
+	text := strings.Repeat("x", chatserver.DefaultMaxMessageLength)
+	for i := 0; i < n; i++ {
+		if err := c.Send(ctx, text); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+}
+
+// online reports whether name is in the server's list of users.
+func online(t *testing.T, ctx context.Context, c *chatclient.Client, name string) bool {
+	t.Helper()
+	users, err := c.ListUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if u.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// A client that stops reading is disconnected, removed and announced as gone, even though
+// the message being sent to it never gets through.
+func TestStuckClientIsDisconnected(t *testing.T) {
+	streams := map[string]func(ctx context.Context, raw gRPC.ChittyChatClient) error{
+		"subscribe": func(ctx context.Context, raw gRPC.ChittyChatClient) error {
+			_, err := raw.Subscribe(ctx, &gRPC.SubMessage{ClientName: "stuck"})
+			return err
+		},
+		"chat": func(ctx context.Context, raw gRPC.ChittyChatClient) error {
+			stream, err := raw.Chat(ctx)
+			if err != nil {
+				return err
+			}
+			return stream.Send(&gRPC.Envelope{Body: &gRPC.Envelope_Hello{Hello: &gRPC.SubMessage{ClientName: "stuck"}}})
+		},
+	}
+	for name, open := range streams {
+		t.Run(name, func(t *testing.T) {
+			addr := startServer(t, chatserver.WithQueueSize(4))
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			c, leaves := talker(t, ctx, addr)
+			if err := open(ctx, rawClient(t, addr)); err != nil {
+				t.Fatal(err)
+			}
+			for !online(t, ctx, c, "stuck") {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			flood(t, ctx, c, 2000)
+			select {
+			case msg := <-leaves:
+				if msg.ClientName != "stuck" {
+					t.Errorf("got the leave message %+v", msg)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the stuck client was never announced as gone")
+			}
+			if online(t, ctx, c, "stuck") {
+				t.Error("the stuck client is still online")
+			}
+			rooms, err := c.ListRooms(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, room := range rooms {
+				for _, member := range room.Members {
+					if member == "stuck" {
+						t.Errorf("the stuck client is still in room %s", room.Name)
+					}
+				}
+			}
+		})
+	}
+}
+
+// reader reads stream on its own goroutine, so it can be read with a timeout.
+// The channel is closed when the subscription ends.
+func reader(stream gRPC.ChittyChat_SubscribeClient) <-chan *gRPC.ChatMessage {
+	msgs := make(chan *gRPC.ChatMessage)
+	go func() {
+		defer close(msgs)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	return msgs
+}
+
+// received takes the messages from msgs until none comes for a while.
+func received(t *testing.T, msgs <-chan *gRPC.ChatMessage) []*gRPC.ChatMessage {
+	t.Helper()
+	var got []*gRPC.ChatMessage
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatal("the subscription ended")
+			}
+			if len(got) > 0 && msg.Sequence <= got[len(got)-1].Sequence {
+				t.Fatalf("got #%d after #%d", msg.Sequence, got[len(got)-1].Sequence)
+			}
+			got = append(got, msg)
+		case <-time.After(500 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+// hasText reports whether one of msgs says text.
+func hasText(msgs []*gRPC.ChatMessage, text string) bool {
+	for _, msg := range msgs {
+		if msg.Message == text {
+			return true
+		}
+	}
+	return false
+}
+
+// With a drop policy a client that stops reading stays subscribed and only misses messages:
+// the oldest ones in its queue, or the new ones that do not fit.
+func TestDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy   chatserver.SlowConsumerPolicy
+		getsLast bool // whether the message sent while the queue is full gets through
+	}{
+		{chatserver.DropOldest, true},
+		{chatserver.DropNewest, false},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			addr := startServer(t, chatserver.WithQueueSize(4), chatserver.WithSlowConsumerPolicy(test.policy, 0))
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			c, leaves := talker(t, ctx, addr)
+			stream, err := rawClient(t, addr).Subscribe(ctx, &gRPC.SubMessage{ClientName: "slow"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for !online(t, ctx, c, "slow") {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			flood(t, ctx, c, 2000)
+			if err := c.Send(ctx, "last"); err != nil {
+				t.Fatal(err)
+			}
+			msgs := reader(stream)
+			got := received(t, msgs)
+			if len(got) == 0 || len(got) >= 2000 {
+				t.Fatalf("got %d messages, want some but not all", len(got))
+			}
+			if hasText(got, "last") != test.getsLast {
+				t.Errorf("got the last message: %v, want %v", !test.getsLast, test.getsLast)
+			}
+			select {
+			case msg := <-leaves:
+				t.Fatalf("got %q, but the slow client should not be disconnected", msg.Text)
+			default:
+			}
+			// once it reads again, it gets everything
+			if err := c.Send(ctx, "after"); err != nil {
+				t.Fatal(err)
+			}
+			if got := received(t, msgs); len(got) != 1 || got[0].Message != "after" {
+				t.Errorf("got %d messages after catching up, want just the one sent then", len(got))
+			}
+		})
+	}
+}
+
+// With BlockWithTimeout the server waits for a slow client rather than drop its messages,
+// so a client that starts reading again within the timeout gets all of them.
+func TestBlockPolicy(t *testing.T) {
+	addr := startServer(t, chatserver.WithQueueSize(4), chatserver.WithSlowConsumerPolicy(chatserver.BlockWithTimeout, time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c, _ := talker(t, ctx, addr)
+	stream, err := rawClient(t, addr).Subscribe(ctx, &gRPC.SubMessage{ClientName: "slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !online(t, ctx, c, "slow") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	flooded := make(chan struct{})
+	go func() {
+		defer close(flooded)
+		for i := 0; i < 2000; i++ {
+			if err := c.Send(ctx, fmt.Sprint(i)); err != nil {
+				t.Errorf("message %d: %v", i, err)
+				return
+			}
+		}
+	}()
+	// long enough for the queue to fill up and the server to wait
+	time.Sleep(500 * time.Millisecond)
+	got := 0
+	for got < 2000 {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("after %d messages: %v", got, err)
+		}
+		if msg.Kind == gRPC.MessageKind_CHAT {
+			got++
+		}
+	}
+	<-flooded
+}
+
+// A Chat client that stops answering heartbeats is disconnected and announced as timed out
+// after missing as many as the server allows, while one that answers them stays.
+func TestHeartbeatTimeout(t *testing.T) {
+	interval, misses := 50*time.Millisecond, 3
+	addr := startServer(t, chatserver.WithHeartbeat(interval, misses), chatserver.WithResumeGrace(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c, leaves := talker(t, ctx, addr, chatclient.WithHeartbeat(interval, misses))
+
+	stream, err := rawClient(t, addr).Chat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&gRPC.Envelope{Body: &gRPC.Envelope_Hello{Hello: &gRPC.SubMessage{ClientName: "silent"}}}); err != nil {
+		t.Fatal(err)
+	}
+	// reads everything, pings too, but never answers
+	pings := 0
+	ended := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		for {
+			env, err := stream.Recv()
+			if err != nil {
+				ended <- err
+				return
+			}
+			if env.GetPing() != nil {
+				pings++
+			}
+		}
+	}()
+
+	select {
+	case msg := <-leaves:
+		if msg.ClientName != "silent" || !strings.Contains(msg.Text, "timed out") {
+			t.Errorf("got the leave message %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the silent client was never announced as timed out")
+	}
+	if took := time.Since(start); took < time.Duration(misses)*interval {
+		t.Errorf("timed out after %s, before missing %d heartbeats", took, misses)
+	}
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the silent client's stream did not end")
+	}
+	if pings == 0 {
+		t.Error("the silent client was never pinged")
+	}
+	if !online(t, ctx, c, "talker") {
+		t.Error("the client answering the heartbeats was disconnected too")
+	}
+}
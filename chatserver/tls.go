@@ -0,0 +1,97 @@
+package chatserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ServerTLS loads the certificate and key of the server for WithTLS. If clientCAFile is
+// not empty, clients are asked for a certificate signed by one of the CAs in it (mutual
+// TLS), and a client that sends one that is not is refused. With requireClientCert,
+// clients without a certificate are refused too, otherwise they can still log in some
+// other way, like with a token.
+func ServerTLS(certFile, keyFile, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile != "" {
+		pool, err := loadCAs(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
+
+// loadCAs reads the PEM certificates in path into a pool.
+func loadCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s has no PEM certificates in it", path)
+	}
+	return pool, nil
+}
+
+// WithTLS makes the server only take TLS connections, set up by config (see ServerTLS).
+// Without it, connections are not encrypted.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.grpcOptions = append(s.grpcOptions, grpc.Creds(credentials.NewTLS(config)))
+	}
+}
+
+// CertAuth returns an AuthFunc for mutual TLS that takes the client name from the common
+// name (CN) of the certificate the client connected with. It only works together with
+// WithTLS and a config that verifies client certificates.
+func CertAuth() AuthFunc {
+	return func(ctx context.Context) (string, error) {
+		p, ok := peer.FromContext(ctx)
+		if !ok {
+			return "", status.Error(codes.Unauthenticated, "no client certificate")
+		}
+		info, ok := p.AuthInfo.(credentials.TLSInfo)
+		if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+			return "", status.Error(codes.Unauthenticated, "no client certificate")
+		}
+		name := info.State.VerifiedChains[0][0].Subject.CommonName
+		if err := checkName(name); err != nil {
+			return "", status.Errorf(codes.Unauthenticated, "the client certificate has no usable name: %s", status.Convert(err).Message())
+		}
+		return name, nil
+	}
+}
+
+// AnyAuth returns an AuthFunc that lets a caller in if any of auths does, trying them in
+// order. A caller none of them let in gets the error of the last one.
+func AnyAuth(auths ...AuthFunc) AuthFunc {
+	return func(ctx context.Context) (string, error) {
+		err := status.Error(codes.Unauthenticated, "no way to log in")
+		for _, auth := range auths {
+			var name string
+			if name, err = auth(ctx); err == nil {
+				return name, nil
+			}
+		}
+		return "", err
+	}
+}
@@ -0,0 +1,208 @@
+package chatserver_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	"github.com/hannaStokes/handin3/devca"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// certs makes a throwaway CA with a certificate for a server on 127.0.0.1 and for every
+// client in clients, and returns the folder they are in.
+func certs(t *testing.T, clients ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := devca.Generate(dir, []string{"127.0.0.1"}, clients); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// tlsServer starts a server that only takes TLS connections with the certificate in dir,
+// and wants client certificates signed by the CA in dir if clientCA is set.
+func tlsServer(t *testing.T, dir string, clientCA bool, opts ...chatserver.Option) string {
+	t.Helper()
+	var ca string
+	if clientCA {
+		ca = filepath.Join(dir, "ca.crt")
+	}
+	config, err := chatserver.ServerTLS(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), ca, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startServer(t, append(opts, chatserver.WithTLS(config))...)
+}
+
+// clientTLS is the TLS option of a client trusting the CA in dir, with the certificate of
+// the client called cert if it is not empty.
+func clientTLS(t *testing.T, dir, cert string) chatclient.Option {
+	t.Helper()
+	var certFile, keyFile string
+	if cert != "" {
+		certFile, keyFile = filepath.Join(dir, "clients", cert+".crt"), filepath.Join(dir, "clients", cert+".key")
+	}
+	config, err := chatclient.ClientTLS(filepath.Join(dir, "ca.crt"), certFile, keyFile, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chatclient.WithTLS(config)
+}
+
+// dialFails reports whether dialing addr with opts fails within a second, as it does when
+// the TLS handshake fails, since gRPC keeps trying until the deadline.
+func dialFails(t *testing.T, addr string, opts ...chatclient.Option) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	opts = append(opts, chatclient.WithLogger(log.New(io.Discard, "", 0)))
+	c, err := chatclient.Dial(ctx, addr, opts...)
+	if err == nil {
+		c.Close()
+		return false
+	}
+	t.Logf("dialing failed as it should: %v", err)
+	return true
+}
+
+// A token is only sent over TLS.
+func TestTokenNeedsTLS(t *testing.T) {
+	dir := certs(t)
+	tokens := chatserver.WithAuth(chatserver.TokenAuth(map[string]string{"alice": "secret"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+
+	plain := startServer(t, tokens)
+	if c, err := chatclient.Dial(ctx, plain, chatclient.WithName("alice"), chatclient.WithToken("secret"), quiet); err == nil {
+		c.Close()
+		t.Fatal("sent a token without TLS")
+	}
+
+	addr := tlsServer(t, dir, false, tokens)
+	c, err := chatclient.Dial(ctx, addr, chatclient.WithName("alice"), chatclient.WithToken("secret"), clientTLS(t, dir, ""), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Passwords and sessions are only sent over TLS, and registering counts against the rate
+// limit of the address it comes from.
+func TestRegisterOverTLS(t *testing.T) {
+	dir := certs(t)
+	accounts, err := chatserver.OpenAccounts(filepath.Join(t.TempDir(), "accounts"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer accounts.Close()
+	opts := []chatserver.Option{
+		chatserver.WithAccounts(accounts),
+		chatserver.WithAuth(accounts.Auth()),
+		chatserver.WithRateLimit(chatserver.RateLimit{}, chatserver.RateLimit{Rate: 0.01, Burst: 2}),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+
+	plain := startServer(t, opts...)
+	if c, err := chatclient.Dial(ctx, plain, chatclient.WithName("alice"), chatclient.WithRegistration("long enough"), quiet); err == nil {
+		c.Close()
+		t.Fatal("sent a password without TLS")
+	}
+
+	addr := tlsServer(t, dir, false, opts...)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		c, err := chatclient.Dial(ctx, addr, chatclient.WithName(name), chatclient.WithRegistration("long enough"), clientTLS(t, dir, ""), quiet)
+		if i < 2 {
+			if err != nil {
+				t.Fatalf("registering %s: %v", name, err)
+			}
+			c.Close()
+			continue
+		}
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("registering a third account at once gave %v, want ResourceExhausted", err)
+		}
+	}
+}
+
+// Over TLS a client only connects if it trusts the server's certificate.
+func TestTLS(t *testing.T) {
+	dir := certs(t)
+	addr := tlsServer(t, dir, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+
+	c, err := chatclient.Dial(ctx, addr, chatclient.WithName("alice"), clientTLS(t, dir, ""), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	chatUntil(t, ctx, c, "hello")
+
+	if !dialFails(t, addr, chatclient.WithName("plain")) {
+		t.Error("a client without TLS connected")
+	}
+	if !dialFails(t, addr, chatclient.WithName("mallory"), clientTLS(t, certs(t), "")) {
+		t.Error("a client trusting another CA connected")
+	}
+}
+
+// With mutual TLS and CertAuth a client is the name in its certificate, and a client
+// without a certificate from the CA is refused.
+func TestMutualTLS(t *testing.T) {
+	dir := certs(t, "alice", "bob")
+	addr := tlsServer(t, dir, true, chatserver.WithAuth(chatserver.CertAuth()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+
+	alice, err := chatclient.Dial(ctx, addr, chatclient.WithName("alice"), clientTLS(t, dir, "alice"), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	if err := alice.Send(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := chatUntil(t, ctx, alice, "hello"); got[len(got)-1].ClientName != "alice" {
+		t.Errorf("the message is from %s", got[len(got)-1].ClientName)
+	}
+
+	if c, err := chatclient.Dial(ctx, addr, chatclient.WithName("alice"), clientTLS(t, dir, "bob"), quiet); status.Code(err) != codes.PermissionDenied {
+		if err == nil {
+			c.Close()
+		}
+		t.Errorf("bob calling himself alice gave %v, want PermissionDenied", err)
+	}
+	if c, err := chatclient.Dial(ctx, addr, chatclient.WithName("carol"), clientTLS(t, dir, ""), quiet); status.Code(err) != codes.Unauthenticated {
+		if err == nil {
+			c.Close()
+		}
+		t.Errorf("a client without a certificate gave %v, want Unauthenticated", err)
+	}
+	other := certs(t, "alice")
+	config, err := chatclient.ClientTLS(filepath.Join(dir, "ca.crt"), filepath.Join(other, "clients", "alice.crt"), filepath.Join(other, "clients", "alice.key"), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dialFails(t, addr, chatclient.WithName("alice"), chatclient.WithTLS(config)) {
+		t.Error("a certificate from another CA was taken")
+	}
+}
@@ -0,0 +1,102 @@
+package chatserver
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hannaStokes/handin3/clock"
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxMessageLength is the longest chat message the server accepts unless
+// WithMaxMessageLength says otherwise, in characters, as in the assignment.
+const DefaultMaxMessageLength = 128
+
+// maxClientName is the longest client name, in characters.
+const maxClientName = 32
+
+// maxClockSize is the most bytes the encoded clock time of a request can have, and
+// maxClockEntries the most entries its vector can have. Every message carries the clock
+// time its sender gave it to everyone in the room, so these keep one client from making
+// messages huge. They leave room for a vector with an entry for every client in each room
+// of a busy server.
+const (
+	maxClockSize    = 128 << 10
+	maxClockEntries = 4096
+)
+
+// checkName checks the client name of a subscription or message.
+func checkName(name string) error {
+	switch {
+	case name == "":
+		return invalid("clientName", "the client name cannot be empty")
+	case !utf8.ValidString(name):
+		return invalid("clientName", "the client name is not valid UTF-8")
+	case utf8.RuneCountInString(name) > maxClientName:
+		return invalid("clientName", fmt.Sprintf("the client name cannot be longer than %d characters", maxClientName))
+	case strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0:
+		return invalid("clientName", "the client name cannot contain spaces or control characters")
+	case clock.IsServerID(name):
+		// the server's entry in vector clocks, which clients must not be able to move
+		return invalid("clientName", fmt.Sprintf("the client name cannot start with %q", clock.ServerID("")))
+	}
+	return nil
+}
+
+// decodeClock decodes the clock time of a subscription or message.
+func decodeClock(encoded []byte) (clock.Time, error) {
+	if len(encoded) > maxClockSize {
+		return clock.Time{}, invalid("clock", fmt.Sprintf("the clock time cannot be longer than %d bytes", maxClockSize))
+	}
+	t, err := clock.Decode(encoded)
+	if err != nil {
+		return clock.Time{}, invalid("clock", fmt.Sprintf("bad clock: %v", err))
+	}
+	if len(t.Vector) > maxClockEntries {
+		return clock.Time{}, invalid("clock", fmt.Sprintf("the clock time cannot have more than %d entries", maxClockEntries))
+	}
+	return t, nil
+}
+
+// checkMessage checks a message a client wants to publish. A message that is too long
+// gets ResourceExhausted, anything else that is wrong with it InvalidArgument.
+func (s *Server) checkMessage(msg *gRPC.ChatMessage) error {
+	if err := checkName(msg.ClientName); err != nil {
+		return err
+	}
+	switch {
+	case !utf8.ValidString(msg.Message):
+		// gRPC already refuses to decode such messages, this is in case that ever changes
+		return invalid("message", "the message is not valid UTF-8")
+	case strings.TrimSpace(msg.Message) == "":
+		return invalid("message", "the message cannot be empty")
+	}
+	if n := utf8.RuneCountInString(msg.Message); n > s.maxLength {
+		st := status.New(codes.ResourceExhausted, fmt.Sprintf("the message is %d characters long, the most is %d", n, s.maxLength))
+		st, _ = st.WithDetails(&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     "message",
+			Description: fmt.Sprintf("at most %d characters", s.maxLength),
+		}}})
+		return st.Err()
+	}
+	if len(msg.MessageId) > maxMessageID {
+		return invalid("messageId", fmt.Sprintf("the message id cannot be longer than %d bytes", maxMessageID))
+	}
+	return nil
+}
+
+// invalid returns an InvalidArgument status that names the field of the request that was wrong.
+func invalid(field, description string) error {
+	st := status.New(codes.InvalidArgument, description)
+	st, _ = st.WithDetails(&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+		Field:       field,
+		Description: description,
+	}}})
+	return st.Err()
+}
@@ -0,0 +1,65 @@
+package chatserver
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hannaStokes/handin3/clock"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"alice", "bob_2", "Ærøskøbing", "a@b"} {
+		if err := checkName(name); err != nil {
+			t.Errorf("checkName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "two words", "tab\tname", "\xff", strings.Repeat("x", maxClientName+1), clock.ServerID("default")} {
+		if err := checkName(name); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("checkName(%q) = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestDecodeClock(t *testing.T) {
+	small := clock.Time{Kind: clock.KindVector, Vector: clock.Vector{"a": 1}}
+	if got, err := decodeClock(small.Encode()); err != nil || !reflect.DeepEqual(got, small) {
+		t.Errorf("decodeClock gave %v, %v", got, err)
+	}
+	many := clock.Vector{}
+	for i := 0; i <= maxClockEntries; i++ {
+		many[fmt.Sprint(i)] = 1
+	}
+	long := clock.Vector{strings.Repeat("x", maxClockSize): 1}
+	tests := map[string][]byte{
+		"too many entries": clock.Time{Kind: clock.KindVector, Vector: many}.Encode(),
+		"too long":         clock.Time{Kind: clock.KindVector, Vector: long}.Encode(),
+		"malformed":        {9},
+	}
+	for name, encoded := range tests {
+		if _, err := decodeClock(encoded); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+// A client can only move its own entries of the server's vector clock.
+func TestObserveClockOnlyTakesOwnEntries(t *testing.T) {
+	s := &Server{logical: clock.NewVectorClock(clock.ServerID("s"))}
+	sent := clock.Time{Kind: clock.KindVector, Vector: clock.Vector{
+		"alice":                             3,
+		clock.RoomEntry("alice", "general"): 2,
+		clock.RoomEntry("alice", "games"):   5,
+		clock.RoomEntry("bob", "general"):   1000,
+		clock.ServerID("s"):                 1000,
+	}}
+	s.observeClock("alice", sent, clock.RoomEntry("alice", "general"))
+	want := clock.Vector{"alice": 3, clock.RoomEntry("alice", "general"): 2, clock.ServerID("s"): 1}
+	if got := s.logical.Now().Vector; !reflect.DeepEqual(got, want) {
+		t.Errorf("the server's clock is %v, want %v", got, want)
+	}
+}
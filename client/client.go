@@ -3,30 +3,61 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-
-	//"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	// this has to be the same as the go.mod module,
-	// followed by the path to the folder the proto file is in.
-	gRPC "github.com/hannaStokes/handin3/proto"
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/clock"
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 )
 
 // Same principle as in client. Flags allows for user specific arguments/values
 var clientsName = flag.String("name", "default", "Senders name")
 var serverPort = flag.String("server", "5400", "Tcp server")
-var clientsTime int64 = 0
+var causalTimeout = flag.Duration("causal", 0, "Hold back messages until the messages they depend on have arrived, waiting at most this long (needs -clock vector)")
+var roomName = flag.String("room", chatclient.DefaultRoom, "Room to start in")
+var historySize = flag.Int("history", 0, "Number of earlier messages to show when joining")
+var keepaliveTime = flag.Duration("keepalive", 30*time.Second, "How long the connection can be idle before gRPC pings the server")
+var heartbeat = flag.Duration("heartbeat", 5*time.Second, "How often the server is sent a heartbeat, 0 to send none")
+var heartbeatMisses = flag.Int("heartbeat-misses", 3, "Heartbeats the server can miss before it is reported unreachable")
+var reconnectDelay = flag.Duration("reconnect", 500*time.Millisecond, "How long to wait before reconnecting after losing the server, doubled after every failed attempt, 0 to not reconnect")
+var reconnectMax = flag.Duration("reconnect-max", 30*time.Second, "Longest wait between attempts to reconnect")
+var token = flag.String("token", "", "Token to log in to the server with, if it wants one (or set $CHITTYCHAT_TOKEN, or use -token-file)")
+var tokenFile = flag.String("token-file", "", "File to read the token from")
+var password = flag.String("password", "", "Password of the account of -name, for servers with accounts (or set $CHITTYCHAT_PASSWORD, or use -password-file)")
+var passwordFile = flag.String("password-file", "", "File to read the password from")
+var register = flag.Bool("register", false, "Register an account for -name with the password before logging in")
+var tlsCA = flag.String("tls-ca", "", "CA certificate file the server certificate has to be signed by. Setting any -tls flag connects with TLS")
+var tlsCert = flag.String("tls-cert", "", "Client certificate file, for servers that log clients in with their certificate. Without -name, the name in it is used")
+var tlsKey = flag.String("tls-key", "", "Key file of the certificate in -tls-cert")
+var tlsServerName = flag.String("tls-server-name", "localhost", "Name the server certificate has to be for")
+var clockKind = flag.String("clock", "lamport", "Logical clock to use: lamport, vector or hlc (vector shows which messages were sent concurrently)")
+
+var client *chatclient.Client //the connection to the server
+
+// messages typed while the client was not connected, sent once it is again
+var outbox struct {
+	sync.Mutex
+	messages []outgoing
+	flushing bool
+}
 
-var server gRPC.ChittyChatClient //the server
-var ServerConn *grpc.ClientConn  //the server connection
+// a typed message
+type outgoing struct {
+	id   string // stays the same when the message is sent again, so the server does not broadcast it twice
+	to   string // who a private message is for, empty for a message to the room
+	text string
+}
 
 func main() {
 	//parse flag/arguments
@@ -40,66 +71,211 @@ func main() {
 
 	//connect to server and close the connection when program closes
 	fmt.Println("--- join Server ---")
-	ConnectToServer()
-	defer ServerConn.Close()
-	go subscribe(server)
+	if err := ConnectToServer(); err != nil {
+		log.Fatalf("Fail to Dial : %v", err)
+	}
+	defer client.Close()
+	go printMessages()
 
 	//start the biding
 	parseInput()
 }
 
 // connect to server
-func ConnectToServer() {
+func ConnectToServer() error {
+	//dial the server, with the flag "server", to get a connection to it
+	kind, err := clock.ParseKind(*clockKind)
+	if err != nil {
+		return err
+	}
+	var tlsOpts []chatclient.Option
+	if *tlsCA != "" || *tlsCert != "" || *tlsKey != "" {
+		config, err := chatclient.ClientTLS(*tlsCA, *tlsCert, *tlsKey, *tlsServerName)
+		if err != nil {
+			return err
+		}
+		tlsOpts = append(tlsOpts, chatclient.WithTLS(config))
+		// the server takes our name from the certificate, so use that unless told otherwise
+		if name := chatclient.CertName(config); name != "" && !nameSet() {
+			*clientsName = name
+		}
+	}
+	opts := []chatclient.Option{
+		chatclient.WithName(*clientsName),
+		chatclient.WithRoom(*roomName),
+		chatclient.WithClock(kind),
+		chatclient.WithCausalDelivery(*causalTimeout),
+		chatclient.WithKeepalive(keepalive.ClientParameters{Time: *keepaliveTime, PermitWithoutStream: true}),
+		chatclient.WithHeartbeat(*heartbeat, *heartbeatMisses),
+		chatclient.WithReconnect(chatclient.Backoff{Initial: *reconnectDelay, Max: *reconnectMax}),
+		chatclient.OnStateChange(stateChanged),
+	}
+	opts = append(opts, tlsOpts...)
+	tok, err := findSecret(*token, *tokenFile, "CHITTYCHAT_TOKEN")
+	if err != nil {
+		return fmt.Errorf("read token: %w", err)
+	}
+	if tok != "" {
+		opts = append(opts, chatclient.WithToken(tok))
+	}
+	pass, err := findSecret(*password, *passwordFile, "CHITTYCHAT_PASSWORD")
+	if err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+	switch {
+	case *register && pass == "":
+		return errors.New("-register needs a password")
+	case *register:
+		opts = append(opts, chatclient.WithRegistration(pass))
+	case pass != "":
+		opts = append(opts, chatclient.WithLogin(pass))
+	}
+	c, err := chatclient.Dial(context.Background(), fmt.Sprintf(":%s", *serverPort), opts...)
+	if err != nil {
+		return err
+	}
+	client = c
+	return nil
+}
 
-	//dial options
-	//the server is not using TLS, so we use insecure credentials
-	//(should be fine for local testing but not in the real world)
-	opts := []grpc.DialOption{
-		grpc.WithBlock(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+// whether -name was given, as opposed to left at its default
+func nameSet() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "name" {
+			set = true
+		}
+	})
+	return set
+}
+
+// a token or password to log in with: the flag value, or else what is in the file, or else
+// the environment variable env. Empty if there is none, for servers that let anyone in.
+func findSecret(value, file, env string) (string, error) {
+	if value != "" {
+		return value, nil
 	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		// only the line break at the end, a password can start or end with spaces
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return os.Getenv(env), nil
+}
 
-	//dial the server, with the flag "server", to get a connection to it
-	log.Printf("client %s: Attempts to dial on port %s\n", *clientsName, *serverPort)
-	conn, err := grpc.Dial(fmt.Sprintf(":%s", *serverPort), opts...)
+// prints every message from the server until the subscription ends
+func printMessages() {
+	first := true
+	for msg := range client.Messages() {
+		if first && *historySize > 0 {
+			// the first message is our own join, everything before it is history
+			printHistory(msg.Sequence)
+		}
+		first = false
+
+		text := displayText(msg)
+		if msg.Clock.IsZero() {
+			fmt.Printf("\"%s\" at timestamp %d\n%s", text, msg.LocalTime, prompt())
+			continue
+		}
+		fmt.Printf("#%d \"%s\" at timestamp %d, %s time %s", msg.Sequence, text, msg.LocalTime, msg.Clock.Kind, msg.Clock)
+		if len(msg.ConcurrentWith) > 0 {
+			fmt.Printf(" (concurrent with %s)", formatSequences(msg.ConcurrentWith))
+		}
+		if msg.Forced {
+			fmt.Print(" (some messages it depends on never arrived)")
+		}
+		fmt.Print("\n" + prompt())
+	}
+	if err := client.Err(); err != nil {
+		log.Printf("Client %s: lost the subscription: %v", *clientsName, err)
+		fmt.Printf("--- disconnected from server: %s ---\n", errorText(err))
+		return
+	}
+	fmt.Println("--- disconnected from server ---")
+}
+
+// prints the last messages of our room sent before the message with sequence number before
+func printHistory(before int64) {
+	msgs, err := client.LastMessages(context.Background(), client.Room(), *historySize, before)
 	if err != nil {
-		log.Printf("Fail to Dial : %v", err)
+		log.Printf("Client %s: could not get the history: %v", *clientsName, err)
 		return
 	}
-	//fmt.Printf("Error here")
-	// makes a client from the server connection and saves the connection
-	// and prints rather or not the connection was is READY
-	server = gRPC.NewChittyChatClient(conn)
-	ServerConn = conn
-	log.Println("the connection is: ", conn.GetState().String())
+	fmt.Printf("--- last %d messages ---\n", len(msgs))
+	for _, msg := range msgs {
+		fmt.Printf("\"%s\" at timestamp %d\n", displayText(msg), msg.Timestamp)
+	}
+	fmt.Print("--- end of history ---\n" + prompt())
 }
 
-func subscribe(client gRPC.ChittyChatClient) error {
-	clientsTime++
-	r := &gRPC.SubMessage{
-		ClientName: *clientsName,
-		Timestamp:  clientsTime,
+// the text shown for a message, chat messages are shown with their sender
+// and messages of a room with the name of the room in front
+func displayText(msg chatclient.Message) string {
+	text := msg.Text
+	switch msg.Kind {
+	case chatclient.KindChat:
+		text = fmt.Sprintf("received message \"%s\" from user %s", msg.Text, msg.ClientName)
+	case chatclient.KindDirect:
+		text = fmt.Sprintf("received private message \"%s\" from user %s", msg.Text, msg.ClientName)
 	}
-	stream, _ := client.Subscribe(context.Background(), r)
-	for {
-		res, err := stream.Recv()
-		if err == io.EOF {
-			break
+	if msg.Room != "" {
+		text = fmt.Sprintf("[%s] %s", msg.Room, text)
+	}
+	return text
+}
+
+// the text shown for an error from the server: why it failed,
+// and what was wrong with the request if the server said so
+func errorText(err error) string {
+	st := status.Convert(err)
+	text := st.Message()
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, v := range d.FieldViolations {
+				text += fmt.Sprintf(" (bad %s)", v.Field)
+			}
+		case *errdetails.QuotaFailure:
+			for _, v := range d.Violations {
+				text += fmt.Sprintf(" (%s: %s)", v.Subject, v.Description)
+			}
+		case *errdetails.RetryInfo:
+			text += fmt.Sprintf(" (try again in %s)", d.RetryDelay.AsDuration().Round(100*time.Millisecond))
 		}
-		if err != nil {
-			return err
+	}
+	return text
+}
+
+// how long the server asked to wait before sending again, if it did
+func retryDelay(err error) (time.Duration, bool) {
+	if status.Code(err) != codes.ResourceExhausted {
+		return 0, false
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if d, ok := detail.(*errdetails.RetryInfo); ok {
+			return d.RetryDelay.AsDuration(), true
 		}
-		IncreaseLamport(res.Timestamp)
-		log.Printf("\"%s\" at timestamp %d", res.Message, clientsTime)
-		fmt.Printf("\"%s\" at timestamp %d\n-> ", res.Message, clientsTime)
 	}
-	return nil
+	return 0, false
+}
+
+// formats sequence numbers as "#3, #4"
+func formatSequences(sequences []int64) string {
+	parts := make([]string, len(sequences))
+	for i, seq := range sequences {
+		parts[i] = fmt.Sprintf("#%d", seq)
+	}
+	return strings.Join(parts, ", ")
 }
 
 func parseInput() {
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Println("Type the message you wish to send below")
-	fmt.Print("-> ")
+	fmt.Println("Type the message you wish to send below, or one of the commands " + commands)
+	fmt.Print(prompt())
 
 	//Infinite loop to listen for clients input.
 	for {
@@ -110,31 +286,218 @@ func parseInput() {
 		}
 		input = strings.TrimSpace(input) //Trim input
 
-		if !conReady(server) {
-			log.Printf("Client %s: something was wrong with the connection to the server :(", *clientsName)
+		if input == "" {
+			fmt.Print(prompt())
+			continue
+		}
+		if strings.HasPrefix(input, "/") {
+			runCommand(input)
+		} else {
+			send(outgoing{text: input})
+		}
+		fmt.Print(prompt())
+	}
+}
+
+// the prompt shown before the user types, with the connection state if it is not connected
+func prompt() string {
+	state := client.State()
+	if state == chatclient.StateConnected {
+		return "-> "
+	}
+	outbox.Lock()
+	queued := len(outbox.messages)
+	outbox.Unlock()
+	if queued > 0 {
+		return fmt.Sprintf("(%s, %d queued) -> ", state, queued)
+	}
+	return fmt.Sprintf("(%s) -> ", state)
+}
+
+// called by the client when it loses the server or gets it back
+func stateChanged(state chatclient.State) {
+	switch state {
+	case chatclient.StateReconnecting:
+		fmt.Print("\n--- lost the connection to the server, reconnecting ---\n" + prompt())
+	case chatclient.StateConnected:
+		fmt.Print("\n--- reconnected to the server ---\n" + prompt())
+		go flushOutbox()
+	}
+}
+
+// sends a typed message, or queues it if the client is not connected
+// (or earlier queued messages still have to go first)
+func send(msg outgoing) {
+	msg.id = chatclient.NewMessageID()
+	outbox.Lock()
+	if client.State() == chatclient.StateDisconnected {
+		outbox.Unlock()
+		fmt.Println("--- not connected to the server, the message was not sent ---")
+		return
+	}
+	if client.State() == chatclient.StateConnected && len(outbox.messages) == 0 && !outbox.flushing {
+		outbox.Unlock()
+		err := sendNow(msg)
+		if status.Code(err) != codes.Unavailable {
+			if err != nil {
+				showRejected(msg, err)
+			}
+			return
+		}
+		// lost the server just now
+		outbox.Lock()
+	}
+	outbox.messages = append(outbox.messages, msg)
+	queued := len(outbox.messages)
+	outbox.Unlock()
+	fmt.Printf("--- not connected, the message will be sent when the client reconnects (%d queued) ---\n", queued)
+}
+
+// sends the queued messages in the order they were typed, until the outbox
+// is empty or the client loses the server again
+func flushOutbox() {
+	outbox.Lock()
+	if outbox.flushing {
+		outbox.Unlock()
+		return
+	}
+	outbox.flushing = true
+	outbox.Unlock()
+	defer func() {
+		outbox.Lock()
+		outbox.flushing = false
+		outbox.Unlock()
+	}()
+
+	for {
+		outbox.Lock()
+		if len(outbox.messages) == 0 || client.State() != chatclient.StateConnected {
+			outbox.Unlock()
+			return
+		}
+		msg := outbox.messages[0]
+		outbox.Unlock()
+		err := sendNow(msg)
+		if status.Code(err) == codes.Unavailable {
+			// kept for the next time the client reconnects
+			return
+		}
+		if wait, ok := retryDelay(err); ok {
+			// sending the queued messages too fast, send this one again in a bit
+			time.Sleep(wait)
 			continue
 		}
-		clientsTime++
-		//Convert string to int64, return error if the int is larger than 32bit or not a number
-		message := &gRPC.ChatMessage{
-			ClientName: *clientsName,
-			Timestamp:  clientsTime,
-			Message:    fmt.Sprintf("received message \"%s\" from user %s", input, *clientsName),
-		}
-		//
-		ack, err := server.Publish(context.Background(), message)
-		//IncreaseLamport(ack.TimeStamp)
-		ack.Timestamp++
 		if err != nil {
-			log.Printf("Client %s: no response from the server, attempting to reconnect", *clientsName)
-			log.Println(err)
+			showRejected(msg, err)
+		}
+		outbox.Lock()
+		outbox.messages = outbox.messages[1:]
+		outbox.Unlock()
+	}
+}
+
+// sends a typed message to the server
+func sendNow(msg outgoing) error {
+	ctx := context.Background()
+	if msg.to != "" {
+		if _, err := client.SendDirectWithID(ctx, msg.id, msg.to, msg.text); err != nil {
+			return err
 		}
+		fmt.Printf("--- sent privately to %s ---\n", msg.to)
+		return nil
 	}
+	_, err := client.SendWithID(ctx, msg.id, msg.text)
+	return err
 }
 
-// Function which returns a true boolean if the connection to the server is ready, and false if it's not.
-func conReady(s gRPC.ChittyChatClient) bool {
-	return ServerConn.GetState().String() == "READY"
+// shows why the server did not take a typed message
+func showRejected(msg outgoing, err error) {
+	log.Printf("Client %s: the server did not take the message: %v", *clientsName, err)
+	if msg.to != "" {
+		fmt.Printf("could not send to %s: %v\n", msg.to, errorText(err))
+		return
+	}
+	fmt.Printf("could not send the message: %v\n", errorText(err))
+}
+
+// the commands that can be typed instead of a message
+const commands = "/msg <name> <message>, /join <room>, /leave [room], /rooms, /who or /status available|away|busy"
+
+// runs one of the commands that can be typed instead of a message
+func runCommand(input string) {
+	ctx := context.Background()
+	fields := strings.Fields(input)
+	switch {
+	case fields[0] == "/msg" && len(fields) >= 3:
+		// keep the spacing of the message itself
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(input, "/msg")), fields[1]))
+		send(outgoing{to: fields[1], text: text})
+
+	case fields[0] == "/join" && len(fields) == 2:
+		if err := client.JoinRoom(ctx, fields[1]); err != nil {
+			fmt.Printf("could not join room %s: %v\n", fields[1], errorText(err))
+			return
+		}
+		fmt.Printf("--- now sending to room %s ---\n", client.Room())
+
+	case fields[0] == "/leave" && len(fields) <= 2:
+		room := client.Room()
+		if len(fields) == 2 {
+			room = fields[1]
+		}
+		if err := client.LeaveRoom(ctx, room); err != nil {
+			fmt.Printf("could not leave room %s: %v\n", room, errorText(err))
+			return
+		}
+		if client.Room() == "" {
+			fmt.Printf("--- left room %s, /join a room to send messages again ---\n", room)
+			return
+		}
+		fmt.Printf("--- left room %s, now sending to room %s ---\n", room, client.Room())
+
+	case fields[0] == "/rooms" && len(fields) == 1:
+		rooms, err := client.ListRooms(ctx)
+		if err != nil {
+			fmt.Printf("could not list the rooms: %v\n", errorText(err))
+			return
+		}
+		for _, room := range rooms {
+			current := ""
+			if room.Name == client.Room() {
+				current = " (sending here)"
+			}
+			fmt.Printf("%s%s: %s\n", room.Name, current, strings.Join(room.Members, ", "))
+		}
+
+	case fields[0] == "/who" && len(fields) == 1:
+		users, err := client.ListUsers(ctx)
+		if err != nil {
+			fmt.Printf("could not list the users: %v\n", errorText(err))
+			return
+		}
+		for _, user := range users {
+			fmt.Printf("%s (%s) online since %s, joined at timestamp %d", user.Name, strings.ToLower(user.Status.String()), user.Connected.Format("15:04:05"), user.JoinedAt)
+			if user.Connections > 1 {
+				fmt.Printf(", connected %d times", user.Connections)
+			}
+			fmt.Println()
+		}
+
+	case fields[0] == "/status" && len(fields) == 2:
+		st, err := chatclient.ParseStatus(fields[1])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := client.SetStatus(ctx, st); err != nil {
+			fmt.Printf("could not set the status: %v\n", errorText(err))
+			return
+		}
+		fmt.Printf("--- you are now %s ---\n", fields[1])
+
+	default:
+		fmt.Println("unknown command, use " + commands)
+	}
 }
 
 // sets the logger to use a log.txt file instead of the console
@@ -146,10 +509,3 @@ func setLog() *os.File {
 	log.SetOutput(f)
 	return f
 }
-
-func IncreaseLamport(timestamp int64) {
-	if clientsTime < timestamp {
-		clientsTime = timestamp
-	}
-	clientsTime++
-}
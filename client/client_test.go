@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hannaStokes/handin3/chatclient"
+	"github.com/hannaStokes/handin3/chatserver"
+	"github.com/hannaStokes/handin3/msglog"
+)
+
+// serve serves a new server with the message log at path on lis, and returns the function
+// that shuts it down.
+func serve(t *testing.T, lis net.Listener, path string) func() {
+	t.Helper()
+	l, err := msglog.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := chatserver.NewServer(chatserver.WithLogger(log.New(io.Discard, "", 0)), chatserver.WithMessageLog(l))
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(lis) }()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		l.Close()
+	}
+}
+
+// Messages typed while the server is gone are sent once the client is back, each once and
+// in the order they were typed.
+func TestOutboxAfterReconnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "messages.log")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	stop := serve(t, lis, path)
+
+	quiet := chatclient.WithLogger(log.New(io.Discard, "", 0))
+	backoff := chatclient.WithReconnect(chatclient.Backoff{Initial: 50 * time.Millisecond, Max: 200 * time.Millisecond})
+	client, err = chatclient.Dial(ctx, addr, chatclient.WithName("typer"), chatclient.OnStateChange(stateChanged), quiet, backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go func(c *chatclient.Client) {
+		for range c.Messages() {
+		}
+	}(client)
+	watcher, err := chatclient.Dial(ctx, addr, chatclient.WithName("watcher"), quiet, backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	stop()
+	for client.State() != chatclient.StateReconnecting {
+		if ctx.Err() != nil {
+			t.Fatal("the client did not notice the server was gone")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	want := []outgoing{{text: "first"}, {text: "second"}, {text: "third"}}
+	for _, msg := range want {
+		send(msg)
+	}
+	outbox.Lock()
+	queued := len(outbox.messages)
+	outbox.Unlock()
+	if queued != len(want) {
+		t.Fatalf("%d messages queued, want %d", queued, len(want))
+	}
+
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serve(t, lis, path)()
+	var got []string
+	for len(got) < len(want) {
+		select {
+		case msg := <-watcher.Messages():
+			if msg.ClientName == "typer" && msg.Kind == chatclient.KindChat {
+				got = append(got, msg.Text)
+			}
+		case <-ctx.Done():
+			t.Fatalf("the watcher only got %v", got)
+		}
+	}
+	for i, msg := range want {
+		if got[i] != msg.text {
+			t.Fatalf("the watcher got %v, want them in the order they were typed", got)
+		}
+	}
+	// anything sent twice would be here by now
+	select {
+	case msg := <-watcher.Messages():
+		if msg.ClientName == "typer" && msg.Kind != chatclient.KindJoin {
+			t.Errorf("got %q again", msg.Text)
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+}
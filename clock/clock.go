@@ -0,0 +1,227 @@
+// Package clock contains the logical clocks used by the ChittyChat server and clients.
+//
+// Every clock implements Clock, so the server and client can be run with whichever
+// one is chosen by their -clock flag. Times are sent between them with Encode and Decode.
+package clock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kind names a kind of logical clock.
+type Kind string
+
+const (
+	KindLamport Kind = "lamport" // a single counter, see Lamport
+	KindVector  Kind = "vector"  // one counter per process, see VectorClock
+	KindHybrid  Kind = "hlc"     // wall-clock time plus a counter, see Hybrid
+)
+
+// ParseKind checks that name is one of "lamport", "vector" or "hlc".
+func ParseKind(name string) (Kind, error) {
+	switch k := Kind(name); k {
+	case KindLamport, KindVector, KindHybrid:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown clock %q, use lamport, vector or hlc", name)
+}
+
+// Clock is the logical clock of one process. Implementations are safe for concurrent use.
+type Clock interface {
+	// Kind tells which kind of clock this is.
+	Kind() Kind
+	// Tick counts a local event, such as sending a message, and returns the new time.
+	Tick() Time
+	// Observe counts receiving a message sent at t and returns the new time.
+	// Times of another kind of clock are ignored, only the receive event is counted.
+	Observe(t Time) Time
+	// Now returns the current time without counting an event.
+	Now() Time
+	// Encode returns the current time encoded for sending, the same as Now().Encode().
+	Encode() []byte
+}
+
+// New makes a clock of the given kind for the process called id.
+// Only vector clocks use the id.
+func New(kind Kind, id string) (Clock, error) {
+	switch kind {
+	case KindLamport:
+		return NewLamport(), nil
+	case KindVector:
+		return NewVectorClock(id), nil
+	case KindHybrid:
+		return NewHybrid(nil), nil
+	}
+	return nil, fmt.Errorf("unknown clock %q", kind)
+}
+
+// Time is a value read from a Clock. Only the fields used by its Kind are set.
+// The zero Time has no kind and is what Decode returns for an empty encoding.
+type Time struct {
+	Kind    Kind
+	Counter int64  // the Lamport counter, or the logical part of a hybrid time
+	Wall    int64  // the wall-clock part of a hybrid time, in nanoseconds since the Unix epoch
+	Vector  Vector // the entries of a vector time
+}
+
+// IsZero reports whether t is the zero Time, meaning no time was sent.
+func (t Time) IsZero() bool {
+	return t.Kind == ""
+}
+
+// Compare tells how t relates to u. Times of different kinds are reported as Concurrent,
+// since nothing can be said about their order. Lamport and hybrid times are totally
+// ordered, so only vector times can be Concurrent with a time of the same kind.
+func (t Time) Compare(u Time) Order {
+	if t.Kind != u.Kind {
+		return Concurrent
+	}
+	switch t.Kind {
+	case KindVector:
+		return t.Vector.Compare(u.Vector)
+	case KindHybrid:
+		if t.Wall != u.Wall {
+			return compareInts(t.Wall, u.Wall)
+		}
+	}
+	return compareInts(t.Counter, u.Counter)
+}
+
+func compareInts(a, b int64) Order {
+	switch {
+	case a < b:
+		return Before
+	case a > b:
+		return After
+	}
+	return Equal
+}
+
+func (t Time) String() string {
+	switch t.Kind {
+	case KindLamport:
+		return fmt.Sprintf("%d", t.Counter)
+	case KindVector:
+		return t.Vector.String()
+	case KindHybrid:
+		return fmt.Sprintf("%s+%d", time.Unix(0, t.Wall).UTC().Format("15:04:05.000000"), t.Counter)
+	}
+	return "-"
+}
+
+// The first byte of an encoded time tells its kind.
+const (
+	tagLamport byte = 1
+	tagVector  byte = 2
+	tagHybrid  byte = 3
+)
+
+// Encode turns t into bytes that Decode turns back into t. The zero Time encodes as nil.
+func (t Time) Encode() []byte {
+	var b []byte
+	switch t.Kind {
+	case KindLamport:
+		b = append(b, tagLamport)
+		b = binary.AppendVarint(b, t.Counter)
+	case KindVector:
+		b = append(b, tagVector)
+		ids := make([]string, 0, len(t.Vector))
+		for id := range t.Vector {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		b = binary.AppendUvarint(b, uint64(len(ids)))
+		for _, id := range ids {
+			b = binary.AppendUvarint(b, uint64(len(id)))
+			b = append(b, id...)
+			b = binary.AppendVarint(b, t.Vector[id])
+		}
+	case KindHybrid:
+		b = append(b, tagHybrid)
+		b = binary.AppendVarint(b, t.Wall)
+		b = binary.AppendVarint(b, t.Counter)
+	}
+	return b
+}
+
+var errMalformed = errors.New("clock: malformed encoded time")
+
+// Decode turns the output of Encode back into a Time. Empty input gives the zero Time.
+func Decode(b []byte) (Time, error) {
+	if len(b) == 0 {
+		return Time{}, nil
+	}
+	r := &reader{b: b[1:]}
+	var t Time
+	switch b[0] {
+	case tagLamport:
+		t = Time{Kind: KindLamport, Counter: r.varint()}
+	case tagVector:
+		count := r.uvarint()
+		t = Time{Kind: KindVector, Vector: Vector{}}
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			id := r.string()
+			t.Vector[id] = r.varint()
+		}
+	case tagHybrid:
+		t = Time{Kind: KindHybrid, Wall: r.varint()}
+		t.Counter = r.varint()
+	default:
+		return Time{}, fmt.Errorf("clock: unknown encoded time tag %d", b[0])
+	}
+	if r.err != nil {
+		return Time{}, r.err
+	}
+	return t, nil
+}
+
+// reader reads the parts of an encoded time. After the first error every read returns
+// a zero value and err stays set.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errMalformed
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errMalformed
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	size := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if uint64(len(r.b)) < size {
+		r.err = errMalformed
+		return ""
+	}
+	s := string(r.b[:size])
+	r.b = r.b[size:]
+	return s
+}
@@ -0,0 +1,174 @@
+package clock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	times := []Time{
+		{},
+		LamportTime(0),
+		LamportTime(42),
+		LamportTime(-1),
+		{Kind: KindVector, Vector: Vector{}},
+		{Kind: KindVector, Vector: Vector{"a": 1, "b": 300, ServerID("main"): 7}},
+		{Kind: KindHybrid, Wall: time.Date(2023, 11, 1, 12, 0, 0, 5, time.UTC).UnixNano(), Counter: 3},
+	}
+	for _, want := range times {
+		got, err := Decode(want.Encode())
+		if err != nil {
+			t.Errorf("Decode(Encode(%v)): %v", want, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode(Encode(%v)) = %#v, want %#v", want, got, want)
+		}
+	}
+	if b := (Time{}).Encode(); b != nil {
+		t.Errorf("the zero Time encodes as %v, want nil", b)
+	}
+}
+
+func TestEncodeIsStable(t *testing.T) {
+	// the same vector gives the same bytes whatever order the map is in
+	v := Time{Kind: KindVector, Vector: Vector{"a": 1, "b": 2, "c": 3, "d": 4}}
+	first := string(v.Encode())
+	for i := 0; i < 20; i++ {
+		if string(v.Encode()) != first {
+			t.Fatal("encoding the same vector gave different bytes")
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	vector := Time{Kind: KindVector, Vector: Vector{"alice": 5}}.Encode()
+	tests := map[string][]byte{
+		"unknown tag":          {9, 1},
+		"lamport no counter":   {tagLamport},
+		"lamport cut off":      {tagLamport, 0x80},
+		"hybrid no counter":    append([]byte{tagHybrid}, 2),
+		"vector cut off":       vector[:len(vector)-1],
+		"vector name too long": {tagVector, 1, 200, 'a'},
+		"vector more entries":  {tagVector, 3, 1, 'a', 2},
+	}
+	for name, b := range tests {
+		if got, err := Decode(b); err == nil {
+			t.Errorf("%s: Decode(%v) = %v, want an error", name, b, got)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	hybrid := func(wall, counter int64) Time { return Time{Kind: KindHybrid, Wall: wall, Counter: counter} }
+	vector := func(v Vector) Time { return Time{Kind: KindVector, Vector: v} }
+	tests := []struct {
+		a, b Time
+		want Order
+	}{
+		{LamportTime(1), LamportTime(2), Before},
+		{LamportTime(2), LamportTime(2), Equal},
+		{LamportTime(3), LamportTime(2), After},
+		{hybrid(10, 5), hybrid(11, 0), Before},
+		{hybrid(10, 5), hybrid(10, 4), After},
+		{hybrid(10, 5), hybrid(10, 5), Equal},
+		{vector(Vector{"a": 1}), vector(Vector{"a": 1, "b": 1}), Before},
+		{vector(Vector{"a": 2, "b": 1}), vector(Vector{"a": 1, "b": 1}), After},
+		{vector(Vector{"a": 2}), vector(Vector{"b": 1}), Concurrent},
+		{vector(Vector{"a": 1, "b": 0}), vector(Vector{"a": 1}), Equal},
+		{vector(Vector{}), vector(Vector{"a": 0}), Equal},
+		{LamportTime(1), hybrid(1, 1), Concurrent},
+		{Time{}, LamportTime(1), Concurrent},
+	}
+	for _, test := range tests {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("%v.Compare(%v) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestLamport(t *testing.T) {
+	l := NewLamport()
+	if got := l.Tick(); got.Counter != 1 {
+		t.Errorf("first tick is %v", got)
+	}
+	if got := l.Observe(LamportTime(10)); got.Counter != 11 {
+		t.Errorf("after observing 10 the time is %v, want 11", got)
+	}
+	if got := l.Observe(LamportTime(3)); got.Counter != 12 {
+		t.Errorf("after observing an older time the time is %v, want 12", got)
+	}
+	if got := l.Observe(Time{Kind: KindHybrid, Counter: 100}); got.Counter != 13 {
+		t.Errorf("a time of another kind moved the clock to %v", got)
+	}
+	if got := l.Now(); got.Counter != 13 {
+		t.Errorf("Now changed the time to %v", got)
+	}
+}
+
+func TestVectorClock(t *testing.T) {
+	a, b := NewVectorClock("a"), NewVectorClock("b")
+	sent := a.Tick()
+	b.Tick()
+	// b's own event and a's message happened without knowing of each other
+	if order := sent.Compare(b.Now()); order != Concurrent {
+		t.Errorf("a's send and b's event are %s", order)
+	}
+	received := b.Observe(sent)
+	if !reflect.DeepEqual(received.Vector, Vector{"a": 1, "b": 2}) {
+		t.Errorf("after receiving b is at %v", received)
+	}
+	if order := sent.Compare(received); order != Before {
+		t.Errorf("the send is %s the receive", order)
+	}
+	// handed out times do not change with the clock
+	b.Tick()
+	if received.Vector["b"] != 2 {
+		t.Error("a time returned by the clock changed when the clock ticked")
+	}
+	if got := b.TickEntry("b in general"); !reflect.DeepEqual(got.Vector, Vector{"a": 1, "b": 3, "b in general": 1}) {
+		t.Errorf("after ticking an entry of its own b is at %v", got)
+	}
+}
+
+func TestHybrid(t *testing.T) {
+	wall := time.Unix(100, 0)
+	h := NewHybrid(func() time.Time { return wall })
+	first := h.Tick()
+	if first.Wall != wall.UnixNano() || first.Counter != 0 {
+		t.Fatalf("first tick is %+v", first)
+	}
+	// the wall clock goes back, the hybrid clock does not
+	wall = wall.Add(-time.Second)
+	second := h.Tick()
+	if second.Compare(first) != After || second.Counter != 1 {
+		t.Errorf("after the wall clock went back the tick is %+v", second)
+	}
+	// a message from a process whose clock is ahead
+	ahead := Time{Kind: KindHybrid, Wall: time.Unix(200, 0).UnixNano(), Counter: 4}
+	if got := h.Observe(ahead); got.Wall != ahead.Wall || got.Counter != 5 {
+		t.Errorf("after observing %+v the time is %+v", ahead, got)
+	}
+	// the wall clock catches up
+	wall = time.Unix(300, 0)
+	if got := h.Tick(); got.Wall != wall.UnixNano() || got.Counter != 0 {
+		t.Errorf("once the wall clock passed, the time is %+v", got)
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for _, name := range []string{"lamport", "vector", "hlc"} {
+		kind, err := ParseKind(name)
+		if err != nil || string(kind) != name {
+			t.Errorf("ParseKind(%q) = %q, %v", name, kind, err)
+		}
+		c, err := New(kind, "p")
+		if err != nil || c.Kind() != kind {
+			t.Errorf("New(%q) made a %v clock, %v", kind, c, err)
+		}
+	}
+	if _, err := ParseKind("sundial"); err == nil {
+		t.Error("ParseKind accepted an unknown clock")
+	}
+}
@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Hybrid is a hybrid logical clock: the highest wall-clock time seen so far, plus a counter
+// that orders events happening within the same wall-clock time. Its times stay close to
+// real time but still respect causality, even if the wall clocks of the processes differ.
+// It implements Clock.
+type Hybrid struct {
+	mutex   sync.Mutex
+	clock   func() time.Time
+	wall    int64
+	counter int64
+}
+
+// NewHybrid makes a hybrid logical clock reading wall-clock time from now.
+// If now is nil, time.Now is used.
+func NewHybrid(now func() time.Time) *Hybrid {
+	if now == nil {
+		now = time.Now
+	}
+	return &Hybrid{clock: now}
+}
+
+func (h *Hybrid) Kind() Kind {
+	return KindHybrid
+}
+
+// Tick moves the clock to the current wall-clock time, or increases the counter if the
+// wall clock has not passed the clock yet.
+func (h *Hybrid) Tick() Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	physical := h.clock().UnixNano()
+	if physical > h.wall {
+		h.wall = physical
+		h.counter = 0
+	} else {
+		h.counter++
+	}
+	return h.time()
+}
+
+// Observe moves the clock past both t and the current wall-clock time.
+func (h *Hybrid) Observe(t Time) Time {
+	if t.Kind != KindHybrid {
+		return h.Tick()
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	physical := h.clock().UnixNano()
+	wall := max(h.wall, t.Wall, physical)
+	switch {
+	case wall == h.wall && wall == t.Wall:
+		h.counter = max(h.counter, t.Counter) + 1
+	case wall == h.wall:
+		h.counter++
+	case wall == t.Wall:
+		h.counter = t.Counter + 1
+	default:
+		h.counter = 0
+	}
+	h.wall = wall
+	return h.time()
+}
+
+func (h *Hybrid) Now() Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.time()
+}
+
+func (h *Hybrid) Encode() []byte {
+	return h.Now().Encode()
+}
+
+// time returns the current value. The mutex must be held.
+func (h *Hybrid) time() Time {
+	return Time{Kind: KindHybrid, Wall: h.wall, Counter: h.counter}
+}
@@ -0,0 +1,53 @@
+package clock
+
+import "sync"
+
+// Lamport is a Lamport clock: a counter that is increased for every event and moved past
+// the time of every received message. It implements Clock.
+type Lamport struct {
+	mutex sync.Mutex
+	time  int64
+}
+
+// NewLamport makes a Lamport clock starting at 0.
+func NewLamport() *Lamport {
+	return &Lamport{}
+}
+
+// LamportTime wraps a Lamport counter sent as a plain number, such as the timestamp of a ChatMessage.
+func LamportTime(counter int64) Time {
+	return Time{Kind: KindLamport, Counter: counter}
+}
+
+func (l *Lamport) Kind() Kind {
+	return KindLamport
+}
+
+// Tick increases the counter by 1.
+func (l *Lamport) Tick() Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.time++
+	return LamportTime(l.time)
+}
+
+// Observe sets the counter to the maximum of itself and t, and then adds 1.
+func (l *Lamport) Observe(t Time) Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if t.Kind == KindLamport && l.time < t.Counter {
+		l.time = t.Counter
+	}
+	l.time++
+	return LamportTime(l.time)
+}
+
+func (l *Lamport) Now() Time {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return LamportTime(l.time)
+}
+
+func (l *Lamport) Encode() []byte {
+	return l.Now().Encode()
+}
@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Vector is a vector clock value: for every process, the number of its events that are
+// known to have happened before. Processes that are missing count as 0.
+type Vector map[string]int64
+
+// serverPrefix starts the id a ChittyChat server uses for itself in vector clocks,
+// so it cannot clash with the name of a client.
+const serverPrefix = "server:"
+
+// ServerID returns the id the server called name uses for itself in vector clocks.
+func ServerID(name string) string {
+	return serverPrefix + name
+}
+
+// IsServerID reports whether id was made by ServerID.
+func IsServerID(id string) bool {
+	return strings.HasPrefix(id, serverPrefix)
+}
+
+// A ChittyChat client counts the chat messages it sends to a room on the entry
+// "<name> in <room>", and the direct messages it sends to another client on
+// "<name> to <recipient>". Neither names nor rooms can contain spaces, so an entry says
+// exactly who sent to where. A client only gets the messages of its own rooms, so the
+// entries of other rooms count messages it will never see; with one entry per sender it
+// could not tell those apart from the ones it is missing.
+
+// RoomEntry returns the entry the client called name counts its messages to room on.
+func RoomEntry(name, room string) string {
+	return name + " in " + room
+}
+
+// DirectEntry returns the entry the client called name counts its direct messages to the
+// client called to on.
+func DirectEntry(name, to string) string {
+	return name + " to " + to
+}
+
+// Order is how two vector clock values relate to each other.
+type Order int
+
+const (
+	Equal      Order = iota // the same events happened before both
+	Before                  // the first value happened before the second
+	After                   // the first value happened after the second
+	Concurrent              // neither happened before the other
+)
+
+func (o Order) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	case Concurrent:
+		return "concurrent"
+	}
+	return fmt.Sprintf("Order(%d)", int(o))
+}
+
+// Copy returns a copy of v that can be changed without changing v.
+func (v Vector) Copy() Vector {
+	c := make(Vector, len(v))
+	for id, n := range v {
+		c[id] = n
+	}
+	return c
+}
+
+// Merge sets every entry of v to the maximum of itself and the same entry in other.
+func (v Vector) Merge(other Vector) {
+	for id, n := range other {
+		if v[id] < n {
+			v[id] = n
+		}
+	}
+}
+
+// Compare tells how v relates to other.
+func (v Vector) Compare(other Vector) Order {
+	less, greater := false, false
+	for id, n := range v {
+		if n < other[id] {
+			less = true
+		} else if n > other[id] {
+			greater = true
+		}
+	}
+	for id, n := range other {
+		if _, ok := v[id]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// String formats v as {a:1, b:2} with the processes sorted by name.
+func (v Vector) String() string {
+	ids := make([]string, 0, len(v))
+	for id := range v {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s:%d", id, v[id])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// VectorClock is the vector clock of one process. It implements Clock.
+type VectorClock struct {
+	mutex sync.Mutex
+	id    string
+	now   Vector
+}
+
+// NewVectorClock makes a vector clock for the process called id.
+func NewVectorClock(id string) *VectorClock {
+	return &VectorClock{id: id, now: Vector{}}
+}
+
+func (c *VectorClock) Kind() Kind {
+	return KindVector
+}
+
+// Tick counts a local event by increasing the entry of this process.
+func (c *VectorClock) Tick() Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now[c.id]++
+	return c.time()
+}
+
+// TickEntry counts a local event on the entry id instead of the entry of this process.
+// A process that sends to several groups can count what it sends to each of them on an
+// entry of its own, so the members of a group only have to wait for entries they can see.
+func (c *VectorClock) TickEntry(id string) Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now[id]++
+	return c.time()
+}
+
+// Observe merges t into the clock and then counts the receive event.
+func (c *VectorClock) Observe(t Time) Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t.Kind == KindVector {
+		c.now.Merge(t.Vector)
+	}
+	c.now[c.id]++
+	return c.time()
+}
+
+func (c *VectorClock) Now() Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.time()
+}
+
+func (c *VectorClock) Encode() []byte {
+	return c.Now().Encode()
+}
+
+// time returns a copy of the current value, so it can be handed out. The mutex must be held.
+func (c *VectorClock) time() Time {
+	return Time{Kind: KindVector, Vector: c.now.Copy()}
+}
@@ -0,0 +1,143 @@
+// Package devca makes a throwaway certificate authority and certificates signed by it, to
+// try out TLS and mutual TLS on a local machine. The certificates are only good for that:
+// the private key of the CA is written next to them, so anyone who can read the directory
+// can make certificates the server trusts.
+//
+// Generate writes these files:
+//
+//	ca.crt, ca.key                     the certificate authority
+//	server.crt, server.key             for the server, valid for the given hosts
+//	clients/<name>.crt, <name>.key     for every client, with the client name as common name (CN)
+package devca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Validity is how long the generated certificates are valid for.
+const Validity = 30 * 24 * time.Hour
+
+// Generate makes a new CA in dir, a server certificate for hosts (names or IP addresses)
+// and a client certificate for every name in clients. dir is created if it does not exist,
+// and files already in it are overwritten.
+func Generate(dir string, hosts, clients []string) error {
+	seen := make(map[string]bool)
+	for _, name := range clients {
+		// the name is used as a file name, so it cannot point anywhere else
+		if name == "" || name == "." || name == ".." || filepath.Base(name) != name || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("client name %q cannot be used as a file name", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("client %s is in the list twice", name)
+		}
+		seen[name] = true
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "clients"), 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ChittyChat development CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(Validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	ca, err := issue(dir, "ca", caTemplate, nil, caKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	server := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "ChittyChat server"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(Validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else {
+			server.DNSNames = append(server.DNSNames, host)
+		}
+	}
+	if err := issueNew(dir, "server", server, ca, caKey); err != nil {
+		return err
+	}
+
+	for _, name := range clients {
+		client := &x509.Certificate{
+			Subject:     pkix.Name{CommonName: name},
+			NotBefore:   now.Add(-time.Hour),
+			NotAfter:    now.Add(Validity),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		if err := issueNew(filepath.Join(dir, "clients"), name, client, ca, caKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issueNew makes a key and a certificate for it from template, signed by the CA.
+func issueNew(dir, name string, template, ca *x509.Certificate, caKey crypto.Signer) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	_, err = issue(dir, name, template, ca, key, caKey)
+	return err
+}
+
+// issue signs a certificate for key made from template with signer, and writes both to
+// dir as name.crt and name.key. A nil parent makes the certificate sign itself.
+func issue(dir, name string, template, parent *x509.Certificate, key *ecdsa.PrivateKey, signer crypto.Signer) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate for %s: %w", name, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePEM(filepath.Join(dir, name+".crt"), "CERTIFICATE", der, 0644); err != nil {
+		return nil, err
+	}
+	if err := writePEM(filepath.Join(dir, name+".key"), "PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func writePEM(path, kind string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), perm)
+}
@@ -0,0 +1,261 @@
+// Package msglog is the append-only message log the ChittyChat server keeps on disk, so
+// chat history survives a restart.
+//
+// The file starts with a short header, followed by one record per message:
+//
+//	length   uint32, big endian, the number of bytes in data
+//	checksum uint32, big endian, CRC-32C of data
+//	data     a LogEntry marshalled with protobuf
+//
+// A record is only ever appended, never changed. If the server stops in the middle of
+// writing one, the file ends part of the way into that record, and Open cuts it off.
+// Any other broken record, one with a bad checksum, an impossible length or complete
+// records after it, means the file was damaged some other way, and Open fails without
+// changing the file rather than throw records away.
+package msglog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// header is written at the start of every log file, so other files are not mistaken for one.
+var header = []byte("CHITTYLOG1\n")
+
+// maxRecord is the largest record Open accepts. Anything bigger must be a broken length.
+const maxRecord = 16 << 20
+
+// errIncomplete is returned by readRecord when the file ends before the record does.
+var errIncomplete = errors.New("incomplete record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Log is an open message log. It is safe for concurrent use.
+type Log struct {
+	mutex     sync.Mutex
+	file      *os.File
+	path      string
+	entries   int   // records in the file
+	truncated int64 // bytes cut off the end by Open because they were not a complete record
+	size      int64 // bytes in the file that hold complete records, where the next one is written
+}
+
+// Open opens the log at path, creating it if it does not exist, and checks every entry in it.
+// A record the file ends in the middle of, left by a crash while writing, is removed.
+// The entries are not kept in memory, use Scan to read them.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("open message log: %w", err)
+	}
+	l := &Log{file: file, path: path}
+	if err := l.recover(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("recover message log %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// recover reads the entries of the file and leaves it positioned for appending.
+func (l *Log) recover() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		if _, err := l.file.Write(header); err != nil {
+			return err
+		}
+		l.size = int64(len(header))
+		return l.file.Sync()
+	}
+
+	r := bufio.NewReader(l.file)
+	start := make([]byte, len(header))
+	if _, err := io.ReadFull(r, start); err != nil || !bytes.Equal(start, header) {
+		return errors.New("not a message log")
+	}
+
+	good := int64(len(header)) // offset just after the last good record
+	for {
+		_, size, err := readRecord(r)
+		if err == io.EOF {
+			// the end of the last record
+			break
+		}
+		if err != nil {
+			if errors.Is(err, errIncomplete) && !l.recordAfter(good, info.Size()) {
+				// the file ends in the middle of the last record, so it is the one that
+				// was being written when the server stopped
+				break
+			}
+			return fmt.Errorf("broken record at byte %d of %d (%v), left the file as it is", good, info.Size(), err)
+		}
+		l.entries++
+		good += size
+	}
+
+	l.truncated = info.Size() - good
+	if l.truncated > 0 {
+		if err := l.file.Truncate(good); err != nil {
+			return err
+		}
+	}
+	l.size = good
+	_, err = l.file.Seek(good, io.SeekStart)
+	return err
+}
+
+// recordAfter reports whether a complete record starts anywhere between offset and end,
+// other than at offset itself. A record that is cut off by the end of the file is only
+// the last one if nothing after it makes sense; otherwise its length is wrong and the
+// rest of the file holds records that were written after it.
+// It is only called for a record the file ends inside of, which is at most maxRecord long,
+// so the bytes after offset fit in memory.
+func (l *Log) recordAfter(offset, end int64) bool {
+	rest := make([]byte, end-offset)
+	if _, err := l.file.ReadAt(rest, offset); err != nil {
+		// cannot tell, so do not cut anything off
+		return true
+	}
+	for i := 1; i+8 <= len(rest); i++ {
+		length := int(binary.BigEndian.Uint32(rest[i : i+4]))
+		if length > len(rest)-i-8 {
+			continue
+		}
+		data := rest[i+8 : i+8+length]
+		if crc32.Checksum(data, crcTable) == binary.BigEndian.Uint32(rest[i+4:i+8]) && proto.Unmarshal(data, &gRPC.LogEntry{}) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readRecord reads one record and returns its entry and its size in the file. A broken
+// record still has the size its header claims, or 0 if not even the header is complete.
+// If the file ends before the record does, the error is errIncomplete.
+func readRecord(r io.Reader) (*gRPC.LogEntry, int64, error) {
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, fmt.Errorf("%w header", errIncomplete)
+		}
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(prefix[0:4])
+	checksum := binary.BigEndian.Uint32(prefix[4:8])
+	size := int64(len(prefix)) + int64(length)
+	if length > maxRecord {
+		return nil, size, errors.New("record too long")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, size, errIncomplete
+		}
+		return nil, size, err
+	}
+	if crc32.Checksum(data, crcTable) != checksum {
+		return nil, size, errors.New("checksum mismatch")
+	}
+	entry := &gRPC.LogEntry{}
+	if err := proto.Unmarshal(data, entry); err != nil {
+		return nil, size, err
+	}
+	return entry, size, nil
+}
+
+// Len returns the number of entries in the log.
+func (l *Log) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.entries
+}
+
+// Scan calls fn with every entry in the log, oldest first, until fn returns false.
+// It reads them from the file, so it takes a while for a long log. Append can go on
+// meanwhile, the entries appended after Scan started are left out.
+func (l *Log) Scan(fn func(*gRPC.LogEntry) bool) error {
+	l.mutex.Lock()
+	size := l.size
+	l.mutex.Unlock()
+
+	r := bufio.NewReader(io.NewSectionReader(l.file, int64(len(header)), size-int64(len(header))))
+	for {
+		entry, _, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read message log %s: %w", l.path, err)
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+}
+
+// Truncated returns how many bytes Open cut off the end of the file because they were
+// not a complete record.
+func (l *Log) Truncated() int64 {
+	return l.truncated
+}
+
+// Path returns the path of the log file.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append writes message to the end of the log, stamped with the wall-clock time at,
+// and waits until it is on disk.
+func (l *Log) Append(message *gRPC.ChatMessage, at time.Time) error {
+	return l.AppendAll([]*gRPC.ChatMessage{message}, at)
+}
+
+// AppendAll writes messages to the end of the log in order, all stamped with the
+// wall-clock time at, and waits until they are on disk. Writing many messages at once
+// costs a single sync, where appending them one by one costs one each.
+// If writing them fails, none of them are added.
+func (l *Log) AppendAll(messages []*gRPC.ChatMessage, at time.Time) error {
+	var records []byte
+	for _, message := range messages {
+		data, err := proto.Marshal(&gRPC.LogEntry{Message: message, UnixNano: at.UnixNano()})
+		if err != nil {
+			return err
+		}
+		records = binary.BigEndian.AppendUint32(records, uint32(len(data)))
+		records = binary.BigEndian.AppendUint32(records, crc32.Checksum(data, crcTable))
+		records = append(records, data...)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err := l.file.Write(records); err != nil {
+		// do not leave half a record in the middle of the file
+		l.file.Truncate(l.size)
+		l.file.Seek(l.size, io.SeekStart)
+		return err
+	}
+	l.size += int64(len(records))
+	l.entries += len(messages)
+	return l.file.Sync()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,207 @@
+package msglog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gRPC "github.com/hannaStokes/handin3/proto"
+)
+
+// writeLog makes a log in a new folder with n messages in it, and returns its path and
+// the offset every record starts at.
+func writeLog(t *testing.T, n int) (string, []int64) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "messages.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	for i := 1; i <= n; i++ {
+		offsets = append(offsets, l.size)
+		if err := l.Append(&gRPC.ChatMessage{ClientName: "a", Message: fmt.Sprint("message ", i), Sequence: int64(i)}, time.Unix(0, int64(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, offsets
+}
+
+// reopen opens the log at path and returns the sequence numbers it recovered.
+func reopen(t *testing.T, path string) (*Log, []int64) {
+	t.Helper()
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	var sequences []int64
+	if err := l.Scan(func(entry *gRPC.LogEntry) bool {
+		sequences = append(sequences, entry.Message.Sequence)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != len(sequences) {
+		t.Fatalf("Len is %d, but Scan found %d entries", l.Len(), len(sequences))
+	}
+	return l, sequences
+}
+
+func change(t *testing.T, path string, edit func([]byte) []byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, edit(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	path, _ := writeLog(t, 3)
+	l, sequences := reopen(t, path)
+	if fmt.Sprint(sequences) != "[1 2 3]" || l.Truncated() != 0 {
+		t.Fatalf("recovered %v, truncated %d, want [1 2 3] and 0", sequences, l.Truncated())
+	}
+	var second *gRPC.LogEntry
+	l.Scan(func(entry *gRPC.LogEntry) bool {
+		second = entry
+		return entry.Message.Sequence < 2
+	})
+	if second.Message.Message != "message 2" || second.UnixNano != 2 {
+		t.Errorf("Scan stopped at %v, want the second entry", second)
+	}
+}
+
+func TestScanWhileAppending(t *testing.T) {
+	path, _ := writeLog(t, 3)
+	l, _ := reopen(t, path)
+	seen := 0
+	l.Scan(func(entry *gRPC.LogEntry) bool {
+		seen++
+		// not seen by this Scan, which started before
+		l.Append(&gRPC.ChatMessage{Sequence: entry.Message.Sequence + 100}, time.Now())
+		return true
+	})
+	if seen != 3 || l.Len() != 6 {
+		t.Errorf("Scan saw %d entries and the log has %d, want 3 and 6", seen, l.Len())
+	}
+}
+
+func TestBrokenTailIsCutOff(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(data []byte, last int64) []byte
+	}{
+		{"half a header", func(data []byte, last int64) []byte { return append(data, 0, 0, 1) }},
+		{"half a record", func(data []byte, last int64) []byte { return data[:len(data)-3] }},
+		{"length past the end", func(data []byte, last int64) []byte { data[last+1] = 1; return data }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, offsets := writeLog(t, 3)
+			change(t, path, func(data []byte) []byte { return test.edit(data, offsets[2]) })
+			l, sequences := reopen(t, path)
+			want := "[1 2 3]"
+			if test.name != "half a header" {
+				want = "[1 2]"
+			}
+			if fmt.Sprint(sequences) != want || l.Truncated() == 0 {
+				t.Fatalf("recovered %v, truncated %d, want %s and something cut off", sequences, l.Truncated(), want)
+			}
+
+			// new records go where the broken one was
+			if err := l.Append(&gRPC.ChatMessage{Sequence: 9}, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			l.Close()
+			_, sequences = reopen(t, path)
+			if fmt.Sprint(sequences) != want[:len(want)-1]+" 9]" {
+				t.Errorf("after appending, recovered %v", sequences)
+			}
+		})
+	}
+}
+
+func TestBrokenRecordInTheMiddleFails(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(data []byte, second int64) []byte
+	}{
+		{"bad checksum", func(data []byte, second int64) []byte { data[second+10] ^= 0xff; return data }},
+		{"bad length", func(data []byte, second int64) []byte { data[second+3]++; return data }},
+		{"length past the end", func(data []byte, second int64) []byte { data[second+1] = 1; return data }},
+		{"huge length", func(data []byte, second int64) []byte { data[second] = 0x7f; return data }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, offsets := writeLog(t, 100)
+			change(t, path, func(data []byte) []byte { return test.edit(data, offsets[1]) })
+			before, _ := os.ReadFile(path)
+			if _, err := Open(path); err == nil {
+				t.Fatal("opened a log with a broken record in the middle")
+			}
+			after, _ := os.ReadFile(path)
+			if !bytes.Equal(before, after) {
+				t.Error("Open changed the file")
+			}
+		})
+	}
+}
+
+func TestBrokenLastRecordFails(t *testing.T) {
+	// the file does not end inside these records, so they were not cut off by a crash
+	tests := []struct {
+		name string
+		edit func(data []byte, last int64) []byte
+	}{
+		{"bad checksum", func(data []byte, last int64) []byte { data[len(data)-1] ^= 0xff; return data }},
+		{"huge length", func(data []byte, last int64) []byte { data[last] = 0x7f; return data }},
+		{"short length", func(data []byte, last int64) []byte { data[last+3]--; return data }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, offsets := writeLog(t, 3)
+			change(t, path, func(data []byte) []byte { return test.edit(data, offsets[2]) })
+			before, _ := os.ReadFile(path)
+			if _, err := Open(path); err == nil {
+				t.Fatal("opened a log with a broken last record")
+			}
+			after, _ := os.ReadFile(path)
+			if !bytes.Equal(before, after) {
+				t.Error("Open changed the file")
+			}
+		})
+	}
+}
+
+func TestNotALog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(path, []byte("shopping list\n"), 0666)
+	if _, err := Open(path); err == nil {
+		t.Fatal("opened a file that is not a message log")
+	}
+}
+
+func TestAppendAll(t *testing.T) {
+	path, _ := writeLog(t, 2)
+	l, _ := reopen(t, path)
+	if err := l.AppendAll([]*gRPC.ChatMessage{{Sequence: 3}, {Sequence: 4}, {Sequence: 5}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AppendAll(nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, sequences := reopen(t, path); fmt.Sprint(sequences) != "[1 2 3 4 5]" {
+		t.Errorf("recovered %v, want [1 2 3 4 5]", sequences)
+	}
+}
@@ -19,16 +19,49 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	ChittyChat_Subscribe_FullMethodName = "/handin3.ChittyChat/Subscribe"
-	ChittyChat_Publish_FullMethodName   = "/handin3.ChittyChat/Publish"
+	ChittyChat_Chat_FullMethodName          = "/handin3.ChittyChat/Chat"
+	ChittyChat_Subscribe_FullMethodName     = "/handin3.ChittyChat/Subscribe"
+	ChittyChat_Publish_FullMethodName       = "/handin3.ChittyChat/Publish"
+	ChittyChat_History_FullMethodName       = "/handin3.ChittyChat/History"
+	ChittyChat_SendDirect_FullMethodName    = "/handin3.ChittyChat/SendDirect"
+	ChittyChat_JoinRoom_FullMethodName      = "/handin3.ChittyChat/JoinRoom"
+	ChittyChat_LeaveRoom_FullMethodName     = "/handin3.ChittyChat/LeaveRoom"
+	ChittyChat_ListRooms_FullMethodName     = "/handin3.ChittyChat/ListRooms"
+	ChittyChat_ListUsers_FullMethodName     = "/handin3.ChittyChat/ListUsers"
+	ChittyChat_SetStatus_FullMethodName     = "/handin3.ChittyChat/SetStatus"
+	ChittyChat_WatchPresence_FullMethodName = "/handin3.ChittyChat/WatchPresence"
+	ChittyChat_Register_FullMethodName      = "/handin3.ChittyChat/Register"
+	ChittyChat_Login_FullMethodName         = "/handin3.ChittyChat/Login"
 )
 
 // ChittyChatClient is the client API for ChittyChat service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ChittyChatClient interface {
+	// Chat does what Subscribe, Publish and SendDirect do, on one stream, see Envelope.
+	Chat(ctx context.Context, opts ...grpc.CallOption) (ChittyChat_ChatClient, error)
+	// Subscribe, Publish and SendDirect are still there for clients that do not use Chat.
 	Subscribe(ctx context.Context, in *SubMessage, opts ...grpc.CallOption) (ChittyChat_SubscribeClient, error)
 	Publish(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*ChatAccept, error)
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryPage, error)
+	// SendDirect sends a private message to the client named in recipient, or fails with
+	// NotFound if that client is not subscribed.
+	SendDirect(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*ChatAccept, error)
+	JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomReply, error)
+	LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomReply, error)
+	ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*RoomList, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*UserList, error)
+	// SetStatus changes the status of a connected user, and returns its new info.
+	SetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*UserInfo, error)
+	// WatchPresence first sends an ONLINE update for every connected user, then an update
+	// whenever a user connects, disconnects or changes its status.
+	WatchPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (ChittyChat_WatchPresenceClient, error)
+	// Register makes an account for the client name, so only whoever knows the password can
+	// use that name, and logs in to it. It fails with AlreadyExists if the name is taken.
+	Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Session, error)
+	// Login starts a session for an account. Both need no session, the other calls do on
+	// servers with accounts.
+	Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Session, error)
 }
 
 type chittyChatClient struct {
@@ -39,8 +72,39 @@ func NewChittyChatClient(cc grpc.ClientConnInterface) ChittyChatClient {
 	return &chittyChatClient{cc}
 }
 
+func (c *chittyChatClient) Chat(ctx context.Context, opts ...grpc.CallOption) (ChittyChat_ChatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChittyChat_ServiceDesc.Streams[0], ChittyChat_Chat_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chittyChatChatClient{stream}
+	return x, nil
+}
+
+type ChittyChat_ChatClient interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ClientStream
+}
+
+type chittyChatChatClient struct {
+	grpc.ClientStream
+}
+
+func (x *chittyChatChatClient) Send(m *Envelope) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *chittyChatChatClient) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *chittyChatClient) Subscribe(ctx context.Context, in *SubMessage, opts ...grpc.CallOption) (ChittyChat_SubscribeClient, error) {
-	stream, err := c.cc.NewStream(ctx, &ChittyChat_ServiceDesc.Streams[0], ChittyChat_Subscribe_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChittyChat_ServiceDesc.Streams[1], ChittyChat_Subscribe_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -80,12 +144,147 @@ func (c *chittyChatClient) Publish(ctx context.Context, in *ChatMessage, opts ..
 	return out, nil
 }
 
+func (c *chittyChatClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryPage, error) {
+	out := new(HistoryPage)
+	err := c.cc.Invoke(ctx, ChittyChat_History_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) SendDirect(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*ChatAccept, error) {
+	out := new(ChatAccept)
+	err := c.cc.Invoke(ctx, ChittyChat_SendDirect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) JoinRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomReply, error) {
+	out := new(RoomReply)
+	err := c.cc.Invoke(ctx, ChittyChat_JoinRoom_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) LeaveRoom(ctx context.Context, in *RoomRequest, opts ...grpc.CallOption) (*RoomReply, error) {
+	out := new(RoomReply)
+	err := c.cc.Invoke(ctx, ChittyChat_LeaveRoom_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*RoomList, error) {
+	out := new(RoomList)
+	err := c.cc.Invoke(ctx, ChittyChat_ListRooms_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*UserList, error) {
+	out := new(UserList)
+	err := c.cc.Invoke(ctx, ChittyChat_ListUsers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) SetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*UserInfo, error) {
+	out := new(UserInfo)
+	err := c.cc.Invoke(ctx, ChittyChat_SetStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) WatchPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (ChittyChat_WatchPresenceClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ChittyChat_ServiceDesc.Streams[2], ChittyChat_WatchPresence_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chittyChatWatchPresenceClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ChittyChat_WatchPresenceClient interface {
+	Recv() (*PresenceUpdate, error)
+	grpc.ClientStream
+}
+
+type chittyChatWatchPresenceClient struct {
+	grpc.ClientStream
+}
+
+func (x *chittyChatWatchPresenceClient) Recv() (*PresenceUpdate, error) {
+	m := new(PresenceUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chittyChatClient) Register(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Session, error) {
+	out := new(Session)
+	err := c.cc.Invoke(ctx, ChittyChat_Register_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chittyChatClient) Login(ctx context.Context, in *Credentials, opts ...grpc.CallOption) (*Session, error) {
+	out := new(Session)
+	err := c.cc.Invoke(ctx, ChittyChat_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ChittyChatServer is the server API for ChittyChat service.
 // All implementations must embed UnimplementedChittyChatServer
 // for forward compatibility
 type ChittyChatServer interface {
+	// Chat does what Subscribe, Publish and SendDirect do, on one stream, see Envelope.
+	Chat(ChittyChat_ChatServer) error
+	// Subscribe, Publish and SendDirect are still there for clients that do not use Chat.
 	Subscribe(*SubMessage, ChittyChat_SubscribeServer) error
 	Publish(context.Context, *ChatMessage) (*ChatAccept, error)
+	History(context.Context, *HistoryRequest) (*HistoryPage, error)
+	// SendDirect sends a private message to the client named in recipient, or fails with
+	// NotFound if that client is not subscribed.
+	SendDirect(context.Context, *ChatMessage) (*ChatAccept, error)
+	JoinRoom(context.Context, *RoomRequest) (*RoomReply, error)
+	LeaveRoom(context.Context, *RoomRequest) (*RoomReply, error)
+	ListRooms(context.Context, *ListRoomsRequest) (*RoomList, error)
+	ListUsers(context.Context, *ListUsersRequest) (*UserList, error)
+	// SetStatus changes the status of a connected user, and returns its new info.
+	SetStatus(context.Context, *StatusRequest) (*UserInfo, error)
+	// WatchPresence first sends an ONLINE update for every connected user, then an update
+	// whenever a user connects, disconnects or changes its status.
+	WatchPresence(*PresenceRequest, ChittyChat_WatchPresenceServer) error
+	// Register makes an account for the client name, so only whoever knows the password can
+	// use that name, and logs in to it. It fails with AlreadyExists if the name is taken.
+	Register(context.Context, *Credentials) (*Session, error)
+	// Login starts a session for an account. Both need no session, the other calls do on
+	// servers with accounts.
+	Login(context.Context, *Credentials) (*Session, error)
 	mustEmbedUnimplementedChittyChatServer()
 }
 
@@ -93,12 +292,45 @@ type ChittyChatServer interface {
 type UnimplementedChittyChatServer struct {
 }
 
+func (UnimplementedChittyChatServer) Chat(ChittyChat_ChatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
 func (UnimplementedChittyChatServer) Subscribe(*SubMessage, ChittyChat_SubscribeServer) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
 func (UnimplementedChittyChatServer) Publish(context.Context, *ChatMessage) (*ChatAccept, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
 }
+func (UnimplementedChittyChatServer) History(context.Context, *HistoryRequest) (*HistoryPage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedChittyChatServer) SendDirect(context.Context, *ChatMessage) (*ChatAccept, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendDirect not implemented")
+}
+func (UnimplementedChittyChatServer) JoinRoom(context.Context, *RoomRequest) (*RoomReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinRoom not implemented")
+}
+func (UnimplementedChittyChatServer) LeaveRoom(context.Context, *RoomRequest) (*RoomReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaveRoom not implemented")
+}
+func (UnimplementedChittyChatServer) ListRooms(context.Context, *ListRoomsRequest) (*RoomList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRooms not implemented")
+}
+func (UnimplementedChittyChatServer) ListUsers(context.Context, *ListUsersRequest) (*UserList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedChittyChatServer) SetStatus(context.Context, *StatusRequest) (*UserInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetStatus not implemented")
+}
+func (UnimplementedChittyChatServer) WatchPresence(*PresenceRequest, ChittyChat_WatchPresenceServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPresence not implemented")
+}
+func (UnimplementedChittyChatServer) Register(context.Context, *Credentials) (*Session, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedChittyChatServer) Login(context.Context, *Credentials) (*Session, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
 func (UnimplementedChittyChatServer) mustEmbedUnimplementedChittyChatServer() {}
 
 // UnsafeChittyChatServer may be embedded to opt out of forward compatibility for this service.
@@ -112,6 +344,32 @@ func RegisterChittyChatServer(s grpc.ServiceRegistrar, srv ChittyChatServer) {
 	s.RegisterService(&ChittyChat_ServiceDesc, srv)
 }
 
+func _ChittyChat_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChittyChatServer).Chat(&chittyChatChatServer{stream})
+}
+
+type ChittyChat_ChatServer interface {
+	Send(*Envelope) error
+	Recv() (*Envelope, error)
+	grpc.ServerStream
+}
+
+type chittyChatChatServer struct {
+	grpc.ServerStream
+}
+
+func (x *chittyChatChatServer) Send(m *Envelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *chittyChatChatServer) Recv() (*Envelope, error) {
+	m := new(Envelope)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _ChittyChat_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SubMessage)
 	if err := stream.RecvMsg(m); err != nil {
@@ -151,6 +409,189 @@ func _ChittyChat_Publish_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChittyChat_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_History_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_SendDirect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).SendDirect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_SendDirect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).SendDirect(ctx, req.(*ChatMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).JoinRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_JoinRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).JoinRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_LeaveRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).LeaveRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_LeaveRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).LeaveRoom(ctx, req.(*RoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_ListRooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRoomsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).ListRooms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_ListRooms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).ListRooms(ctx, req.(*ListRoomsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_SetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).SetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_SetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).SetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_WatchPresence_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PresenceRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChittyChatServer).WatchPresence(m, &chittyChatWatchPresenceServer{stream})
+}
+
+type ChittyChat_WatchPresenceServer interface {
+	Send(*PresenceUpdate) error
+	grpc.ServerStream
+}
+
+type chittyChatWatchPresenceServer struct {
+	grpc.ServerStream
+}
+
+func (x *chittyChatWatchPresenceServer) Send(m *PresenceUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ChittyChat_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).Register(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChittyChat_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Credentials)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChittyChatServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChittyChat_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChittyChatServer).Login(ctx, req.(*Credentials))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ChittyChat_ServiceDesc is the grpc.ServiceDesc for ChittyChat service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -162,13 +603,60 @@ var ChittyChat_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Publish",
 			Handler:    _ChittyChat_Publish_Handler,
 		},
+		{
+			MethodName: "History",
+			Handler:    _ChittyChat_History_Handler,
+		},
+		{
+			MethodName: "SendDirect",
+			Handler:    _ChittyChat_SendDirect_Handler,
+		},
+		{
+			MethodName: "JoinRoom",
+			Handler:    _ChittyChat_JoinRoom_Handler,
+		},
+		{
+			MethodName: "LeaveRoom",
+			Handler:    _ChittyChat_LeaveRoom_Handler,
+		},
+		{
+			MethodName: "ListRooms",
+			Handler:    _ChittyChat_ListRooms_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _ChittyChat_ListUsers_Handler,
+		},
+		{
+			MethodName: "SetStatus",
+			Handler:    _ChittyChat_SetStatus_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _ChittyChat_Register_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _ChittyChat_Login_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chat",
+			Handler:       _ChittyChat_Chat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "Subscribe",
 			Handler:       _ChittyChat_Subscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchPresence",
+			Handler:       _ChittyChat_WatchPresence_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/go.proto",
 }
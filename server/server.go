@@ -4,37 +4,61 @@ import (
 	"context"
 	"flag"
 	"fmt"
-
-	// "io"
 	"log"
 	"net"
 	"os"
-	"sync"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	// this has to be the same as the go.mod module,
-	// followed by the path to the folder the proto file is in.
-	gRPC "github.com/hannaStokes/handin3/proto"
+	"github.com/hannaStokes/handin3/chatserver"
+	"github.com/hannaStokes/handin3/clock"
+	"github.com/hannaStokes/handin3/devca"
+	"github.com/hannaStokes/handin3/msglog"
 
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 )
 
-type Server struct {
-	gRPC.UnimplementedChittyChatServer        // You need this line if you have a server. "ChittyChat" should be the equivalent name of your service
-	name                               string // Not required but useful if you want to name your server
-	port                               string // Not required but useful if your server needs to know what port it's listening to
-
-	channelList []chan gRPC.ChatMessage
-
-	currentTime int64      // value that clients can increment.
-	mutex       sync.Mutex // used to lock the server to avoid race conditions.
-}
-
 // flags are used to get arguments from the terminal. Flags take a value, a default value and a description of the flag.
 // to use a flag then just add it as an argument when running the program.
 var serverName = flag.String("name", "default", "Senders name") // set with "-name <name>" in terminal
 var port = flag.String("port", "5400", "Server port")           // set with "-port <port>" in terminal
+var queueSize = flag.Int("queue", 64, "Number of messages that can wait to be sent to each client")
+var slowPolicy = flag.String("slow-policy", "disconnect", "What to do with clients that cannot keep up: disconnect, drop-oldest, drop-newest or block (which holds up the whole server while it waits)")
+var blockTimeout = flag.Duration("block-timeout", time.Second, "How long the block policy waits for a slow client, during which no other client gets anything")
+var clockKind = flag.String("clock", "lamport", "Logical clock to use: lamport, vector or hlc (the Lamport timestamp is always kept)")
+var messageLog = flag.String("messages", "messages.log", "File that every message is persisted to and recovered from on start, empty to keep messages only in memory")
+var historySize = flag.Int("history-size", chatserver.DefaultHistorySize, "Number of the newest messages kept in memory, older history is read from -messages")
+var keepaliveTime = flag.Duration("keepalive-time", 30*time.Second, "How long a connection can be idle before gRPC pings the client")
+var keepaliveTimeout = flag.Duration("keepalive-timeout", 10*time.Second, "How long gRPC waits for the answer to its ping before closing the connection")
+var heartbeat = flag.Duration("heartbeat", 5*time.Second, "How often clients are sent a heartbeat, 0 to send none")
+var heartbeatMisses = flag.Int("heartbeat-misses", 3, "Heartbeats a client can miss before it is disconnected as timed out")
+var resumeGrace = flag.Duration("resume-grace", 10*time.Second, "How long a disconnected client can take to reconnect before its leaving is announced")
+var dedupWindow = flag.Duration("dedup-window", 5*time.Minute, "How long message ids are remembered so a message sent again is not broadcast twice, 0 to not deduplicate")
+var maxLength = flag.Int("max-length", chatserver.DefaultMaxMessageLength, "Longest message clients can send, in characters")
+var rate = flag.Float64("rate", 2, "Messages per second each client can send on average, 0 for no limit")
+var burst = flag.Int("burst", 10, "Messages each client can send at once before -rate applies, at least 1")
+var addressRate = flag.Float64("address-rate", 10, "Messages per second all clients from one address can send together on average, 0 for no limit")
+var addressBurst = flag.Int("address-burst", 30, "Messages all clients from one address can send at once before -address-rate applies, at least 1")
+var muteStrikes = flag.Int("mute-strikes", 0, "Times a client can go over the rate limit within -mute-within before it is muted, 0 to never mute")
+var muteWithin = flag.Duration("mute-within", time.Minute, "How far back going over the rate limit counts towards a mute")
+var muteFor = flag.Duration("mute", time.Minute, "How long a client is muted for")
+var tokens = flag.String("tokens", "", "File with a client name and its token on every line. If set, only clients with one of those tokens can connect, and only under the name it belongs to")
+var accountsFile = flag.String("accounts", "", "File to keep user accounts in. If set, clients register and log in with a password, and can only use the name of their account. The names in -tokens cannot be registered, and it cannot be used with -tls-client-ca")
+var sessionTTL = flag.Duration("session-ttl", chatserver.DefaultSessionTTL, "How long a login lasts without being used")
+var tlsCert = flag.String("tls-cert", "", "Certificate file of the server. If set with -tls-key, clients have to connect with TLS")
+var tlsKey = flag.String("tls-key", "", "Key file of the certificate in -tls-cert")
+var tlsClientCA = flag.String("tls-client-ca", "", "CA certificate file client certificates have to be signed by. If set, clients log in with their certificate (mutual TLS) and the common name in it is their name")
+var shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "How long to wait for clients to receive their last messages when shutting down")
 
 func main() {
+	// "server gencerts" makes certificates for trying out TLS instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "gencerts" {
+		genCerts(os.Args[2:])
+		return
+	}
+
 	f := setLog() //uncomment this line to log to a log.txt file instead of the console
 	defer f.Close()
 
@@ -42,10 +66,9 @@ func main() {
 	flag.Parse()
 	fmt.Println(".:server is starting:.")
 
-	// launch the server
+	// launch the server, it runs until the process gets SIGINT (Ctrl-C) or SIGTERM
 	launchServer()
-
-	// code here is unreachable because launchServer occupies the current thread.
+	fmt.Println(".:server stopped:.")
 }
 
 func launchServer() {
@@ -58,99 +81,149 @@ func launchServer() {
 		return
 	}
 
-	// makes gRPC server using the options
-	// you can add options here if you want or remove the options part entirely
-	var opts []grpc.ServerOption
-	grpcServer := grpc.NewServer(opts...)
+	policy, err := chatserver.ParseSlowConsumerPolicy(*slowPolicy)
+	if err != nil {
+		log.Fatalf("Server %s: %v", *serverName, err)
+	}
+	kind, err := clock.ParseKind(*clockKind)
+	if err != nil {
+		log.Fatalf("Server %s: %v", *serverName, err)
+	}
 
 	// makes a new server instance using the name and port from the flags.
-	server := &Server{
-		name:        *serverName,
-		port:        *port,
-		currentTime: 0,
-		channelList: make([]chan gRPC.ChatMessage, 0),
+	opts := []chatserver.Option{
+		chatserver.WithName(*serverName),
+		chatserver.WithPort(*port),
+		chatserver.WithQueueSize(*queueSize),
+		chatserver.WithHistorySize(*historySize),
+		chatserver.WithSlowConsumerPolicy(policy, *blockTimeout),
+		chatserver.WithClock(kind),
+		chatserver.WithKeepalive(
+			keepalive.ServerParameters{Time: *keepaliveTime, Timeout: *keepaliveTimeout},
+			// clients may ping a bit more often than we do, but not so often that it is abuse
+			keepalive.EnforcementPolicy{MinTime: *keepaliveTime / 3, PermitWithoutStream: true},
+		),
+		chatserver.WithHeartbeat(*heartbeat, *heartbeatMisses),
+		chatserver.WithResumeGrace(*resumeGrace),
+		chatserver.WithDedupWindow(*dedupWindow),
+		chatserver.WithMaxMessageLength(*maxLength),
+		chatserver.WithRateLimit(chatserver.RateLimit{Rate: *rate, Burst: *burst}, chatserver.RateLimit{Rate: *addressRate, Burst: *addressBurst}),
+		chatserver.WithMute(chatserver.Mute{Strikes: *muteStrikes, Within: *muteWithin, Duration: *muteFor}),
 	}
-
-	gRPC.RegisterChittyChatServer(grpcServer, server) //Registers the server to the gRPC server.
-
-	log.Printf("Server %s: Listening at %v\n\n", *serverName, list.Addr())
-
-	if err := grpcServer.Serve(list); err != nil {
-		log.Fatalf("failed to serve %v", err)
+	if *accountsFile != "" && *tlsClientCA != "" {
+		// anyone could register the name in someone else's certificate and log in as them
+		log.Fatalf("Server %s: -accounts cannot be used together with -tls-client-ca", *serverName)
 	}
-	// code here is unreachable because grpcServer.Serve occupies the current thread.
-}
-
-// The method format can be found in the pb.go file. If the format is wrong, the server type will give an error.
-func (s *Server) Subscribe(in *gRPC.SubMessage, stream gRPC.ChittyChat_SubscribeServer) error {
-	name := in.ClientName
-	log.Printf("User: %s is subscribing", name)
-	IncreaseLamport(s, in.Timestamp)
-
-	channel := make(chan gRPC.ChatMessage)
-	s.channelList = append(s.channelList, channel)
-	log.Printf("Added channel to list.\n           Number of subscribed clients: %d \n", len(s.channelList))
-	go recv(s, channel, stream)
-
-	msg := fmt.Sprintf("User %s subscribed", name)
-	//log.Printf(msg)
-	broadcast(s, gRPC.ChatMessage{ClientName: name, Timestamp: s.currentTime, Message: msg})
-	<-stream.Context().Done()
-	//remove stream from s.channelList and send out "user logged off" message to remaining channels
-	//Locate channel in channelList
-	for i, c := range s.channelList {
-		if c == channel {
-			s.channelList[i] = nil
-			if i == 0 {
-				s.channelList = s.channelList[1:]
-			} else if i == len(s.channelList) {
-				s.channelList = s.channelList[:i]
-			} else {
-				s.channelList = append(s.channelList[:i], s.channelList[i+1:]...)
-			}
-			break
+	var auths []chatserver.AuthFunc
+	if *tlsCert != "" || *tlsKey != "" {
+		// with tokens too, clients without a certificate can still log in with their token
+		config, err := chatserver.ServerTLS(*tlsCert, *tlsKey, *tlsClientCA, *tokens == "")
+		if err != nil {
+			log.Fatalf("Server %s: %v", *serverName, err)
 		}
+		opts = append(opts, chatserver.WithTLS(config))
+		if *tlsClientCA != "" {
+			log.Printf("Server %s: Letting in clients with a certificate signed by %s", *serverName, *tlsClientCA)
+			auths = append(auths, chatserver.CertAuth())
+		}
+	} else if *tlsClientCA != "" {
+		log.Fatalf("Server %s: -tls-client-ca needs -tls-cert and -tls-key", *serverName)
+	}
+	var known map[string]string
+	if *tokens != "" {
+		if *tlsCert == "" {
+			// clients do not send their tokens without TLS, and anyone listening could take them
+			log.Fatalf("Server %s: -tokens needs -tls-cert and -tls-key", *serverName)
+		}
+		var err error
+		known, err = chatserver.LoadTokens(*tokens)
+		if err != nil {
+			log.Fatalf("Server %s: %v", *serverName, err)
+		}
+		log.Printf("Server %s: Letting in the %d clients in %s", *serverName, len(known), *tokens)
+		auths = append(auths, chatserver.TokenAuth(known))
+	}
+	if *accountsFile != "" {
+		if *tlsCert == "" {
+			// passwords and session tokens would go over the network as they are
+			log.Fatalf("Server %s: -accounts needs -tls-cert and -tls-key", *serverName)
+		}
+		accounts, err := chatserver.OpenAccounts(*accountsFile, *sessionTTL)
+		if err != nil {
+			log.Fatalf("Server %s: %v", *serverName, err)
+		}
+		defer accounts.Close()
+		// the clients with tokens keep their names to themselves
+		names := make([]string, 0, len(known))
+		for name := range known {
+			names = append(names, name)
+		}
+		if err := accounts.Reserve(names...); err != nil {
+			log.Fatalf("Server %s: %v, give it a new token or remove its account from %s", *serverName, err, *accountsFile)
+		}
+		log.Printf("Server %s: Letting in clients logged in to one of the %d accounts in %s", *serverName, accounts.Len(), *accountsFile)
+		opts = append(opts, chatserver.WithAccounts(accounts))
+		auths = append(auths, accounts.Auth())
 	}
+	if len(auths) > 0 {
+		opts = append(opts, chatserver.WithAuth(chatserver.AnyAuth(auths...)))
+	}
+	if *messageLog != "" {
+		l, err := msglog.Open(*messageLog)
+		if err != nil {
+			log.Fatalf("Server %s: %v", *serverName, err)
+		}
+		defer l.Close()
+		opts = append(opts, chatserver.WithMessageLog(l))
+	}
+	server := chatserver.NewServer(opts...)
 
-	log.Printf("Removed channel from list.\n           Number of subscribed clients: %d \n", len(s.channelList))
-	lvmsg := fmt.Sprintf("User %s left the server", name)
-	broadcast(s, gRPC.ChatMessage{ClientName: name, Timestamp: s.currentTime, Message: lvmsg})
-	return nil
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(list) }()
 
-func IncreaseLamport(s *Server, timestamp int64) {
-	log.Printf("Comparing Lamport times and adding 1 to max.\n           Server: %d, Client: %d \n", s.currentTime, timestamp)
-	if s.currentTime < timestamp {
-		s.currentTime = timestamp
+	select {
+	case err := <-served:
+		log.Fatalf("failed to serve %v", err)
+	case <-ctx.Done():
 	}
-	s.currentTime++
-	//log.Printf("Lamport time is now: %d", s.currentTime)
-}
 
-func broadcast(s *Server, message gRPC.ChatMessage) {
-	log.Printf("Broadcasting message \"%s\" to all users.\n           Increasing Lamport Time %d by 1. \n \n", message.Message, s.currentTime)
-	s.currentTime++ //receive and send are separate events
-	for _, channel := range s.channelList {
-		channel <- message
+	fmt.Println(".:server is shutting down:.")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server %s: Clients did not disconnect in time, closed their connections: %v", *serverName, err)
 	}
+	<-served
 }
 
-func recv(s *Server, channel chan gRPC.ChatMessage, stream gRPC.ChittyChat_SubscribeServer) {
-	for {
-		//Don't put logs or prints in here! This is run once for all clients, everytime something is broadcast!
-		var recv = <-channel
-		//IncreaseLamport(s, recv.Timestamp) already handled in Publish
-		stream.Send(&gRPC.ChatMessage{ClientName: recv.ClientName, Timestamp: s.currentTime, Message: recv.Message})
+// genCerts is the gencerts subcommand. It writes a throwaway CA and certificates signed
+// by it for the server and the clients, to try out -tls-cert and -tls-client-ca locally.
+func genCerts(args []string) {
+	flags := flag.NewFlagSet("gencerts", flag.ExitOnError)
+	dir := flags.String("dir", "certs", "Folder to write the certificates to")
+	hosts := flags.String("hosts", "localhost,127.0.0.1", "Comma separated names and IP addresses the server certificate is for")
+	clients := flags.String("clients", "", "Comma separated client names to make client certificates for, the names go in their common name")
+	flags.Parse(args)
+
+	if err := devca.Generate(*dir, splitList(*hosts), splitList(*clients)); err != nil {
+		fmt.Fprintf(os.Stderr, "gencerts: %v\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf("wrote a CA and certificates to %s, only use them for testing\n", *dir)
 }
 
-func (s *Server) Publish(ctx context.Context, ChatMessage *gRPC.ChatMessage) (*gRPC.ChatAccept, error) {
-	log.Printf("Message being published")
-	IncreaseLamport(s, ChatMessage.Timestamp)
-	broadcast(s, *ChatMessage)
-	name := s.name
-	//IncreaseLamport(s,ChatMessage.Timestamp)
-	return &gRPC.ChatAccept{ServerName: name, Timestamp: s.currentTime}, nil
+// splitList splits a comma separated list, leaving out empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // Get preferred outbound ip of this machine